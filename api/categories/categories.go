@@ -0,0 +1,130 @@
+// package categories contains the categories products are grouped into on the menu.
+package categories
+
+import (
+	"cmp"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/fold"
+)
+
+// SampleData is the categories of the sample products.
+//
+//go:embed data.json
+var SampleData []byte
+
+// Category is a group of products on the menu, products reference it by ID.
+type Category struct {
+	// ID is a slug, see [fold.Slug], so it can be used in URLs, "creme-brulee" for Crème Brûlée.
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// SortOrder is where the category appears on the menu, lowest first.
+	SortOrder   int    `json:"sortOrder"`
+	Description string `json:"description,omitempty"`
+}
+
+// Store is the interface for looking up categories.
+type Store interface {
+	// Get returns the category with id or an [apperr.CodeNotFound] error if there isn't one.
+	Get(ctx context.Context, id string) (Category, error)
+	// List returns every category ordered by SortOrder then ID.
+	List(ctx context.Context) ([]Category, error)
+}
+
+var _ Store = &Mem{}
+
+// Mem is a [Store] of a fixed set of categories held in memory.
+type Mem struct {
+	byID   map[string]Category
+	sorted []Category
+}
+
+// NewMem creates a [Mem] store of cs, see [Validate].
+func NewMem(cs ...Category) (*Mem, error) {
+	if err := Validate(cs); err != nil {
+		return nil, err
+	}
+	m := &Mem{byID: make(map[string]Category, len(cs)), sorted: slices.Clone(cs)}
+	for _, v := range cs {
+		m.byID[v.ID] = v
+	}
+	slices.SortFunc(m.sorted, func(a, b Category) int {
+		return cmp.Or(cmp.Compare(a.SortOrder, b.SortOrder), cmp.Compare(a.ID, b.ID))
+	})
+	return m, nil
+}
+
+// Get implements [Store.Get].
+func (m *Mem) Get(ctx context.Context, id string) (Category, error) {
+	if err := ctx.Err(); err != nil {
+		return Category{}, err
+	}
+	c, has := m.byID[id]
+	if !has {
+		return Category{}, apperr.NewError(apperr.CodeNotFound, fmt.Errorf("category %s not found", id))
+	}
+	return c, nil
+}
+
+// List implements [Store.List].
+func (m *Mem) List(ctx context.Context) ([]Category, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return slices.Clone(m.sorted), nil
+}
+
+// Validate checks every category in cs has a unique slug ID and a name.
+func Validate(cs []Category) error {
+	ve := []error{}
+	seen := map[string]struct{}{}
+	for i, v := range cs {
+		if v.ID == "" {
+			ve = append(ve, fmt.Errorf("category[%d] id is required", i))
+		} else if _, has := seen[v.ID]; has {
+			ve = append(ve, fmt.Errorf("category[%d] id %s is duplicated", i, v.ID))
+		} else if slug := fold.Slug(v.ID); slug != v.ID {
+			ve = append(ve, fmt.Errorf("category[%d] id %s must be a slug such as %s", i, v.ID, slug))
+		}
+		seen[v.ID] = struct{}{}
+		if v.Name == "" {
+			ve = append(ve, fmt.Errorf("category[%d] name is required", i))
+		}
+	}
+	if len(ve) > 0 {
+		return errors.Join(ve...)
+	}
+	return nil
+}
+
+// Parse decodes a JSON list of categories from b, see [Validate].
+func Parse(b []byte) ([]Category, error) {
+	cs := []Category{}
+	if err := json.Unmarshal(b, &cs); err != nil {
+		return nil, err
+	}
+	if err := Validate(cs); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// Load reads the JSON list of categories in the file at path, see [Parse].
+func Load(path string) ([]Category, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid categories %s: %w", path, err)
+	}
+	return cs, nil
+}
@@ -0,0 +1,73 @@
+package categories
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMem(t *testing.T) {
+	t.Parallel()
+
+	cake := Category{ID: "cake", Name: "Cakes", SortOrder: 2}
+	pie := Category{ID: "pie", Name: "Pies", SortOrder: 1, Description: "Sweet pies"}
+	tart := Category{ID: "tart", Name: "Tarts", SortOrder: 2}
+
+	t.Run("looks up categories", func(t *testing.T) {
+		t.Parallel()
+		m, err := NewMem(tart, cake, pie)
+		require.NoError(t, err)
+
+		c, err := m.Get(t.Context(), "pie")
+		require.NoError(t, err)
+		assert.Equal(t, pie, c)
+
+		cs, err := m.List(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, []Category{pie, cake, tart}, cs, "ordered by sort order then id")
+	})
+
+	t.Run("no category", func(t *testing.T) {
+		t.Parallel()
+		m, err := NewMem(cake)
+		require.NoError(t, err)
+		_, err = m.Get(t.Context(), "pie")
+		ae, ok := err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, apperr.CodeNotFound, ae.Code)
+		assert.ErrorContains(t, err, "category pie not found")
+	})
+
+	t.Run("invalid categories", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewMem(cake, Category{ID: "cake", Name: "Cakes"}, Category{Name: "Pies"}, Category{ID: "tart"})
+		assert.EqualError(t, err, "category[1] id cake is duplicated\ncategory[2] id is required\ncategory[3] name is required")
+
+		_, err = NewMem(Category{ID: "Crème Brûlée", Name: "Crème Brûlée"})
+		assert.EqualError(t, err, "category[0] id Crème Brûlée must be a slug such as creme-brulee")
+	})
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	cs, err := Parse(SampleData)
+	require.NoError(t, err)
+	assert.NotEmpty(t, cs)
+
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	require.NoError(t, os.WriteFile(good, []byte(`[{"id":"pie","name":"Pies","sortOrder":1}]`), 0o644))
+	cs, err = Load(good)
+	require.NoError(t, err)
+	assert.Equal(t, []Category{{ID: "pie", Name: "Pies", SortOrder: 1}}, cs)
+
+	bad := filepath.Join(dir, "bad.json")
+	require.NoError(t, os.WriteFile(bad, []byte(`[{"id":"pie"}]`), 0o644))
+	_, err = Load(bad)
+	assert.ErrorContains(t, err, "category[0] name is required")
+}
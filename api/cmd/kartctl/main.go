@@ -0,0 +1,152 @@
+// kartctl is an operator tool for working with the data behind the kart server.
+//
+// usage:
+//
+//	kartctl orders export -orders-dir DIR [-format jsonl|csv] [-from TIME] [-to TIME] [-columns id,total] [-o FILE]
+//	kartctl orders import -orders-dir DIR [-format jsonl|csv] [-i FILE]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/matgreaves/kart-challenge/api/orders"
+)
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	if err := run(ctx, os.Stdin, os.Stdout, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, in io.Reader, out io.Writer, args []string) error {
+	if len(args) < 2 || args[0] != "orders" {
+		return errors.New("usage: kartctl orders export|import [flags]")
+	}
+	switch args[1] {
+	case "export":
+		return exportOrders(ctx, out, args[2:])
+	case "import":
+		return importOrders(ctx, in, args[2:])
+	default:
+		return fmt.Errorf("unknown orders command %q", args[1])
+	}
+}
+
+func exportOrders(ctx context.Context, out io.Writer, args []string) (err error) {
+	flags := flag.NewFlagSet("orders export", flag.ContinueOnError)
+	dir := flags.String("orders-dir", "", "directory the server stores orders in")
+	format := flags.String("format", string(orders.FormatJSONL), "output format, jsonl or csv")
+	from := flags.String("from", "", "only export orders created at or after this RFC 3339 time or date")
+	to := flags.String("to", "", "only export orders created before this RFC 3339 time, or on or before this date")
+	columns := flags.String("columns", "", "comma separated columns to export, every column when empty: "+strings.Join(orders.Columns, ","))
+	file := flags.String("o", "", "file to write to, stdout when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	opts := orders.ExportOptions{Format: orders.Format(*format)}
+	if opts.CreatedFrom, err = parseTime(*from, false); err != nil {
+		return fmt.Errorf("invalid -from: %w", err)
+	}
+	if opts.CreatedTo, err = parseTime(*to, true); err != nil {
+		return fmt.Errorf("invalid -to: %w", err)
+	}
+	if *columns != "" {
+		opts.Columns = strings.Split(*columns, ",")
+	}
+	if *dir == "" {
+		return errors.New("-orders-dir is required")
+	}
+	// read only so exporting is safe while the server is running
+	s, err := orders.ReadFile(*dir)
+	if err != nil {
+		return err
+	}
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); cerr != nil {
+				err = errors.Join(err, cerr)
+			}
+		}()
+		out = f
+	}
+	n, err := orders.Export(ctx, out, s, opts)
+	if err != nil {
+		return err
+	}
+	log.Printf("exported %d orders", n)
+	return nil
+}
+
+func importOrders(ctx context.Context, in io.Reader, args []string) (err error) {
+	flags := flag.NewFlagSet("orders import", flag.ContinueOnError)
+	dir := flags.String("orders-dir", "", "directory the server stores orders in, must hold no orders")
+	format := flags.String("format", string(orders.FormatJSONL), "input format, jsonl or csv")
+	file := flags.String("i", "", "file to read from, stdin when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	s, err := openOrders(*dir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := s.Close(); cerr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to close orders store: %w", cerr))
+		}
+	}()
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	n, err := orders.Import(ctx, in, s, orders.Format(*format))
+	if err != nil {
+		return fmt.Errorf("imported %d orders before failing: %w", n, err)
+	}
+	log.Printf("imported %d orders", n)
+	return nil
+}
+
+// openOrders opens the file backed orders store in dir, failing if the server has it open.
+func openOrders(dir string) (*orders.File, error) {
+	if dir == "" {
+		return nil, errors.New("-orders-dir is required")
+	}
+	return orders.OpenFile(dir, orders.DefaultSnapshotEvery, slog.Default())
+}
+
+// parseTime parses s as either an RFC 3339 time or a date, the zero time when empty. When
+// wholeDay is set a date is parsed as the start of the following day, so that as an exclusive
+// bound it includes every order on that date.
+func parseTime(s string, wholeDay bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		if wholeDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matgreaves/kart-challenge/api/money"
+	"github.com/matgreaves/kart-challenge/api/orders"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrders(t *testing.T) {
+	t.Parallel()
+
+	// seed stores three orders a day apart in a new orders dir.
+	seed := func(t *testing.T) (string, []orders.Order) {
+		t.Helper()
+		dir := t.TempDir()
+		f, err := orders.OpenFile(dir, 0, slog.New(slog.DiscardHandler))
+		require.NoError(t, err)
+		created := []orders.Order{}
+		for i := range 3 {
+			o, err := f.Create(t.Context(), orders.Order{
+				Items:     []orders.OrderItem{{ProductID: "1", Quantity: i + 1}},
+				Status:    orders.StatusPlaced,
+				CreatedAt: time.Date(2025, 3, 1+i, 12, 0, 0, 0, time.UTC),
+				CreatedBy: "client",
+				Totals: orders.Totals{
+					Subtotal: money.New(650, "AUD"),
+					Discount: money.New(0, "AUD"),
+					Total:    money.New(650, "AUD"),
+				},
+			})
+			require.NoError(t, err)
+			created = append(created, o)
+		}
+		require.NoError(t, f.Close())
+		return dir, created
+	}
+	// stored lists every order in dir.
+	stored := func(t *testing.T, dir string) []orders.Order {
+		t.Helper()
+		f, err := orders.OpenFile(dir, 0, slog.New(slog.DiscardHandler))
+		require.NoError(t, err)
+		defer f.Close()
+		p, err := f.List(t.Context(), orders.ListQuery{Limit: orders.MaxListLimit})
+		require.NoError(t, err)
+		return p.Orders
+	}
+
+	for _, format := range []string{"jsonl", "csv"} {
+		t.Run(format+" round trip", func(t *testing.T) {
+			t.Parallel()
+			src, want := seed(t)
+			var out bytes.Buffer
+			require.NoError(t, run(t.Context(), nil, &out, []string{"orders", "export", "-orders-dir", src, "-format", format}))
+
+			dst := t.TempDir()
+			require.NoError(t, run(t.Context(), &out, nil, []string{"orders", "import", "-orders-dir", dst, "-format", format}))
+			assert.Equal(t, want, stored(t, dst))
+		})
+	}
+
+	t.Run("date range and columns", func(t *testing.T) {
+		t.Parallel()
+		src, want := seed(t)
+		file := filepath.Join(t.TempDir(), "orders.csv")
+		require.NoError(t, run(t.Context(), nil, nil, []string{
+			"orders", "export", "-orders-dir", src, "-format", "csv",
+			"-from", "2025-03-02", "-to", "2025-03-03T12:00:00Z", "-columns", "id,total", "-o", file,
+		}))
+
+		b, err := os.ReadFile(file)
+		require.NoError(t, err)
+		assert.Equal(t, "id,total\n"+want[1].ID+",6.5\n", string(b))
+	})
+
+	t.Run("date only -to includes its day", func(t *testing.T) {
+		t.Parallel()
+		src, want := seed(t)
+		var out bytes.Buffer
+		require.NoError(t, run(t.Context(), nil, &out, []string{
+			"orders", "export", "-orders-dir", src, "-format", "csv", "-to", "2025-03-02", "-columns", "id",
+		}))
+		assert.Equal(t, "id\n"+want[0].ID+"\n"+want[1].ID+"\n", out.String())
+	})
+
+	t.Run("store in use", func(t *testing.T) {
+		t.Parallel()
+		dir, want := seed(t)
+		f, err := orders.OpenFile(dir, 0, slog.New(slog.DiscardHandler))
+		require.NoError(t, err)
+		defer f.Close()
+
+		var out bytes.Buffer
+		require.NoError(t, run(t.Context(), nil, &out, []string{"orders", "export", "-orders-dir", dir, "-format", "csv", "-columns", "id"}))
+		assert.Equal(t, "id\n"+want[0].ID+"\n"+want[1].ID+"\n"+want[2].ID+"\n", out.String())
+
+		err = run(t.Context(), strings.NewReader(""), nil, []string{"orders", "import", "-orders-dir", dir})
+		assert.ErrorContains(t, err, "is already open")
+	})
+
+	t.Run("import into a store with orders", func(t *testing.T) {
+		t.Parallel()
+		dir, _ := seed(t)
+		err := run(t.Context(), strings.NewReader(""), nil, []string{"orders", "import", "-orders-dir", dir})
+		assert.ErrorContains(t, err, "orders can only be imported into an empty store")
+	})
+
+	t.Run("unknown command", func(t *testing.T) {
+		t.Parallel()
+		assert.EqualError(t, run(t.Context(), nil, nil, []string{"orders", "delete"}), `unknown orders command "delete"`)
+		assert.ErrorContains(t, run(t.Context(), nil, nil, []string{"orders", "export"}), "-orders-dir is required")
+	})
+}
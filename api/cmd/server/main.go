@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -9,14 +10,18 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 
+	"github.com/matgreaves/kart-challenge/api/categories"
 	"github.com/matgreaves/kart-challenge/api/coupons"
+	"github.com/matgreaves/kart-challenge/api/idempotency"
+	"github.com/matgreaves/kart-challenge/api/inventory"
+	"github.com/matgreaves/kart-challenge/api/locations"
 	"github.com/matgreaves/kart-challenge/api/monitoring"
 	"github.com/matgreaves/kart-challenge/api/orders"
 	"github.com/matgreaves/kart-challenge/api/products"
 	"github.com/matgreaves/kart-challenge/api/server"
+	"github.com/matgreaves/kart-challenge/api/webhooks"
 	"go.opentelemetry.io/otel"
 )
 
@@ -34,9 +39,24 @@ func main() {
 	}
 }
 
-func run(ctx context.Context, args []string) error {
+func run(ctx context.Context, args []string) (err error) {
 	flags := flag.NewFlagSet("", flag.ExitOnError)
 	addr := flags.String("a", DefaultAddress, "host:port to listen on")
+	policy := orders.DefaultPolicy
+	flags.IntVar(&policy.MinQuantity, "order-min-quantity", policy.MinQuantity, "smallest quantity allowed on an order line")
+	flags.IntVar(&policy.MaxQuantity, "order-max-quantity", policy.MaxQuantity, "largest quantity allowed of a single product, 0 for no limit")
+	flags.IntVar(&policy.MaxLines, "order-max-lines", policy.MaxLines, "largest number of lines allowed in an order, 0 for no limit")
+	flags.IntVar(&policy.MaxUnits, "order-max-units", policy.MaxUnits, "largest number of units allowed in an order, 0 for no limit")
+	var couponLimits coupons.Limits
+	flags.IntVar(&couponLimits.MaxRedemptions, "coupon-max-redemptions", 0, "number of times each coupon can be used in total, 0 for no limit")
+	flags.IntVar(&couponLimits.MaxPerAPIKey, "coupon-max-per-key", 0, "number of times each coupon can be used by a single API key, 0 for no limit")
+	flags.IntVar(&couponLimits.MaxPerCustomer, "coupon-max-per-customer", 0, "number of times each coupon can be used by a single customer, 0 for no limit")
+	categoriesPath := flags.String("categories", "", "JSON file to load product categories from, the embedded sample categories are used when empty")
+	productsPath := flags.String("products", "", "JSON or CSV file, or directory of files, to load products from, the embedded sample products are used when empty")
+	productsInterval := flags.Duration("products-reload-interval", products.DefaultReloadInterval, "how often to check the products file for changes, 0 to never check")
+	locationsDir := flags.String("locations", "", "directory holding a products file, or directory of files, for each location other than the default named after the location's ID")
+	ordersDir := flags.String("orders-dir", "", "directory to durably store orders in, orders are kept in memory when empty")
+	webhookInterval := flags.Duration("webhook-interval", webhooks.DefaultPollInterval, "how often to check for webhook events to deliver")
 	if err := flags.Parse(args); err != nil {
 		return err
 	}
@@ -49,18 +69,78 @@ func run(ctx context.Context, args []string) error {
 	}
 	otel.SetTracerProvider(tp)
 
-	ps := products.NewSlice(products.SampleData)
-	cs, err := coupons.NewMem(strings.NewReader(coupons.DB))
-	ors := orders.NewMem()
+	logger := monitoring.NewJSONLogger(os.Stdout, DefaultLogLevel)
+	cats, err := categories.Parse(categories.SampleData)
 	if err != nil {
 		return err
 	}
+	if *categoriesPath != "" {
+		if cats, err = categories.Load(*categoriesPath); err != nil {
+			return err
+		}
+	}
+	var ps products.Store
+	if *productsPath != "" {
+		f, err := products.OpenFile(*productsPath, cats, logger)
+		if err != nil {
+			return err
+		}
+		go f.Watch(ctx, *productsInterval)
+		ps = f
+	} else if ps, err = products.NewMem(products.NewSlice(products.SampleData), cats); err != nil {
+		return fmt.Errorf("invalid sample products: %w", err)
+	}
+	menus := []locations.Menu{{Location: locations.Location{ID: locations.DefaultID, Name: "Default"}, Products: ps}}
+	if *locationsDir != "" {
+		more, err := locations.OpenDir(ctx, *locationsDir, cats, logger, *productsInterval)
+		if err != nil {
+			return err
+		}
+		menus = append(menus, more...)
+	}
+	ls, err := locations.NewMem(menus...)
+	if err != nil {
+		return err
+	}
+	catStore, err := categories.NewMem(cats...)
+	if err != nil {
+		return err
+	}
+	packed, err := coupons.NewPacked(coupons.DB)
+	if err != nil {
+		return err
+	}
+	if err := couponLimits.Validate(); err != nil {
+		return err
+	}
+	cs := coupons.WithLimits(packed, couponLimits)
+	ors := orders.NewMem()
+	if *ordersDir != "" {
+		f, err := orders.OpenFile(*ordersDir, orders.DefaultSnapshotEvery, logger)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); cerr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to close orders store: %w", cerr))
+			}
+		}()
+		ors = f
+	}
 	return server.Server{
-		Logger:   monitoring.NewJSONLogger(os.Stdout, DefaultLogLevel),
-		Products: ps,
-		Orders:   ors,
-		Coupons:  cs,
-		Auth:     server.TestAuth(),
-		Addr:     *addr,
+		Logger:          logger,
+		Products:        ps,
+		Locations:       ls,
+		Categories:      catStore,
+		Orders:          ors,
+		Coupons:         cs,
+		Redemptions:     coupons.NewMemRedemptions(),
+		Inventory:       inventory.NewMem(logger),
+		OrderPolicy:     &policy,
+		Idempotency:     idempotency.NewMem(idempotency.DefaultRetention),
+		Webhooks:        webhooks.NewMem(),
+		WebhookInterval: *webhookInterval,
+		Auth:            server.TestAuth(),
+		Addr:            *addr,
 	}.Run(ctx)
 }
@@ -9,12 +9,26 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"maps"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/matgreaves/kart-challenge/api/categories"
+	"github.com/matgreaves/kart-challenge/api/inventory"
+	"github.com/matgreaves/kart-challenge/api/locations"
+	"github.com/matgreaves/kart-challenge/api/money"
 	"github.com/matgreaves/kart-challenge/api/orders"
 	"github.com/matgreaves/kart-challenge/api/products"
 	"github.com/matgreaves/kart-challenge/api/server"
+	"github.com/matgreaves/kart-challenge/api/webhooks"
 	grun "github.com/matgreaves/run"
 	exp "github.com/matgreaves/run/exp"
 	"github.com/matgreaves/run/exp/ports"
@@ -28,19 +42,74 @@ func TestListProduct(t *testing.T) {
 	addr, close := startServer(t)
 	defer noErr(t, close)
 
-	res, err := http.Get("http://" + addr + "/product")
-	require.NoError(t, err)
-	defer res.Body.Close()
-	assert.Equal(t, http.StatusOK, res.StatusCode)
+	sample := products.NewSlice(products.SampleData)
 
-	b, err := io.ReadAll(res.Body)
-	require.NoError(t, err)
-	var got []products.Product
-	err = json.Unmarshal(b, &got)
-	require.NoError(t, err)
-	expected, err := products.NewSlice(products.SampleData).List(t.Context(), 0, 100)
-	require.NoError(t, err)
-	assert.Equal(t, expected, got)
+	t.Run("every product", func(t *testing.T) {
+		var got []products.Product
+		res := do(t, addr, http.MethodGet, "/product", "", nil, &got)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []products.Product(sample), got)
+		assert.Equal(t, strconv.Itoa(len(sample)), res.Header.Get("X-Total-Count"))
+		assert.Empty(t, res.Header.Get("Link"))
+	})
+
+	t.Run("page", func(t *testing.T) {
+		var got []products.Product
+		res := do(t, addr, http.MethodGet, "/product?page=1&pageSize=4", "", nil, &got)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []products.Product(sample[4:8]), got)
+		assert.Equal(t, strconv.Itoa(len(sample)), res.Header.Get("X-Total-Count"))
+		assert.Equal(t, `</product?page=2&pageSize=4>; rel="next", </product?page=0&pageSize=4>; rel="prev"`, res.Header.Get("Link"))
+	})
+
+	t.Run("page past the end", func(t *testing.T) {
+		var got []products.Product
+		res := do(t, addr, http.MethodGet, "/product?page=9&pageSize=4", "", nil, &got)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Empty(t, got)
+		assert.Equal(t, `</product?page=2&pageSize=4>; rel="prev"`, res.Header.Get("Link"))
+	})
+
+	t.Run("huge page", func(t *testing.T) {
+		var se server.ServerError
+		res := do(t, addr, http.MethodGet, "/product?page=92233720368547759", "", nil, &se)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeValidation, Message: "page is too large"}, se)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		var got []products.Product
+		res := do(t, addr, http.MethodGet, "/product?name=creme+BRULEE", "", nil, &got)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []products.Product{sample[1]}, got)
+
+		res = do(t, addr, http.MethodGet, "/product?category=Pie", "", nil, &got)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []products.Product{sample[5]}, got)
+	})
+
+	t.Run("filter and sort", func(t *testing.T) {
+		var got []products.Product
+		res := do(t, addr, http.MethodGet, "/product?maxPrice=5&sort=-price&pageSize=3", "", nil, &got)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []products.Product{sample[5], sample[6], sample[7]}, got)
+		assert.Equal(t, "4", res.Header.Get("X-Total-Count"))
+		assert.Equal(t, `</product?maxPrice=5&page=1&pageSize=3&sort=-price>; rel="next"`, res.Header.Get("Link"))
+	})
+
+	t.Run("unknown sort field", func(t *testing.T) {
+		var se server.ServerError
+		res := do(t, addr, http.MethodGet, "/product?sort=colour", "", nil, &se)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeValidation, Message: `unknown sort field "colour", must be one of name or price`}, se)
+	})
+
+	t.Run("invalid page", func(t *testing.T) {
+		var se server.ServerError
+		res := do(t, addr, http.MethodGet, "/product?page=-1&pageSize=101", "", nil, &se)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeValidation, Message: "page must be zero or greater\npageSize must be between 1 and 100"}, se)
+	})
 }
 
 func TestGetProduct(t *testing.T) {
@@ -180,6 +249,12 @@ func TestCreateOrder(t *testing.T) {
 		prod, err := productStore.Get(t.Context(), goodOrder().Items[0].ProductID)
 		require.NoError(t, err)
 		assert.Equal(t, []products.Product{prod}, o.Products)
+		assert.Equal(t, orders.Totals{
+			Lines:    []orders.LinePrice{{ProductID: prod.ID, Quantity: 1, UnitPrice: money.New(650, "AUD"), Total: money.New(650, "AUD")}},
+			Subtotal: money.New(650, "AUD"),
+			Discount: money.New(0, "AUD"),
+			Total:    money.New(650, "AUD"),
+		}, o.Totals)
 	})
 
 	t.Run("valid coupon", func(t *testing.T) {
@@ -208,6 +283,9 @@ func TestCreateOrder(t *testing.T) {
 		prod, err := productStore.Get(t.Context(), goodOrder().Items[0].ProductID)
 		require.NoError(t, err)
 		assert.Equal(t, []products.Product{prod}, o.Products)
+		assert.Equal(t, &orders.CouponResult{Code: "OVER9000", Applied: true}, o.Coupon)
+		assert.Equal(t, money.New(65, "AUD"), o.Discount)
+		assert.Equal(t, money.New(585, "AUD"), o.Total)
 	})
 
 	t.Run("coupon too short", func(t *testing.T) {
@@ -233,6 +311,31 @@ func TestCreateOrder(t *testing.T) {
 		assert.Equal(t, server.ServerError{Code: server.ErrCodeConstraint, Message: "invalid couponCode specified"}, se)
 	})
 
+	t.Run("coupon limited per API key", func(t *testing.T) {
+		addr, close := startServer(t, "-coupon-max-per-key", "1")
+		defer noErr(t, close)
+
+		or := goodOrder()
+		or.CouponCode = "OVER9000"
+		placeOrder(t, addr, "apitest", or)
+		placeOrder(t, addr, "apitest2", or)
+
+		b, err := json.Marshal(or)
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, "http://"+addr+"/order", bytes.NewReader(b))
+		require.NoError(t, err)
+		req.Header.Set(server.APIKeyHeader, "apitest")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+		var se server.ServerError
+		err = json.NewDecoder(res.Body).Decode(&se)
+		require.NoError(t, err)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeConstraint, Message: "coupon OVER9000 has no uses left for this API key"}, se)
+	})
+
 	t.Run("missing productID", func(t *testing.T) {
 		addr, close := startServer(t)
 		defer noErr(t, close)
@@ -299,8 +402,973 @@ func TestCreateOrder(t *testing.T) {
 		var se server.ServerError
 		err = json.NewDecoder(res.Body).Decode(&se)
 		require.NoError(t, err)
-		assert.Equal(t, server.ServerError{Code: server.ErrCodeConstraint, Message: "item[0] quantity cannot be less than zero"}, se)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeConstraint, Message: "item[0] quantity must be at least 1"}, se)
+	})
+
+	t.Run("too many items", func(t *testing.T) {
+		addr, close := startServer(t, "-order-max-lines", "2")
+		defer noErr(t, close)
+
+		or := goodOrder()
+		or.Items = append(or.Items, or.Items[0], or.Items[0])
+		b, err := json.Marshal(or)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, "http://"+addr+"/order", bytes.NewReader(b))
+		require.NoError(t, err)
+		req.Header.Set(server.APIKeyHeader, "apitest")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+		var se server.ServerError
+		err = json.NewDecoder(res.Body).Decode(&se)
+		require.NoError(t, err)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeConstraint, Message: "order cannot have more than 2 items"}, se)
+	})
+
+	t.Run("duplicate items are combined", func(t *testing.T) {
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		or := goodOrder()
+		or.Items = append(or.Items, or.Items[0])
+		o := placeOrder(t, addr, "apitest", or)
+		assert.Equal(t, []orders.OrderItem{{ProductID: "1", Quantity: 2}}, o.Items)
+		assert.Len(t, o.Products, 1)
+	})
+}
+
+func TestCreateOrderIdempotency(t *testing.T) {
+	t.Parallel()
+
+	// post places or as apiKey with the Idempotency-Key key decoding the response into v.
+	post := func(t *testing.T, addr, apiKey, key string, or orders.OrderReq, v any) *http.Response {
+		t.Helper()
+		req := newRequest(t, addr, http.MethodPost, "/order", apiKey, or)
+		req.Header.Set(server.IdempotencyKeyHeader, key)
+		return send(t, req, v)
+	}
+
+	t.Run("retry replays original order", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		var want, got orders.Order
+		first := post(t, addr, "apitest", "retry", goodOrder(), &want)
+		require.Equal(t, http.StatusOK, first.StatusCode)
+		assert.Empty(t, first.Header.Get(server.IdempotentReplayedHeader))
+
+		second := post(t, addr, "apitest", "retry", goodOrder(), &got)
+		require.Equal(t, http.StatusOK, second.StatusCode)
+		assert.Equal(t, "true", second.Header.Get(server.IdempotentReplayedHeader))
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("replays failures", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		or := goodOrder()
+		or.Items[0].ProductID = "9999"
+		for range 2 {
+			res := post(t, addr, "apitest", "bad", or, nil)
+			assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		}
+	})
+
+	t.Run("key reused with different payload", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		require.Equal(t, http.StatusOK, post(t, addr, "apitest", "reused", goodOrder(), nil).StatusCode)
+
+		or := goodOrder()
+		or.Items[0].Quantity = 2
+		var se server.ServerError
+		res := post(t, addr, "apitest", "reused", or, &se)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeConstraint, Message: "Idempotency-Key has already been used with a different request"}, se)
+	})
+
+	t.Run("keys are scoped to api key", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		var a, b orders.Order
+		require.Equal(t, http.StatusOK, post(t, addr, "apitest", "shared", goodOrder(), &a).StatusCode)
+		require.Equal(t, http.StatusOK, post(t, addr, "apitest2", "shared", goodOrder(), &b).StatusCode)
+		assert.NotEqual(t, a.ID, b.ID)
+	})
+
+	t.Run("concurrent duplicates create one order", func(t *testing.T) {
+		t.Parallel()
+		addr, stop := startServer(t)
+		defer noErr(t, stop)
+
+		ids := make(chan string, 10)
+		wg := sync.WaitGroup{}
+		for range cap(ids) {
+			req := newRequest(t, addr, http.MethodPost, "/order", "apitest", goodOrder())
+			req.Header.Set(server.IdempotencyKeyHeader, "concurrent")
+			wg.Go(func() {
+				res, err := http.DefaultClient.Do(req)
+				if !assert.NoError(t, err) {
+					return
+				}
+				defer res.Body.Close()
+				var o orders.Order
+				assert.NoError(t, json.NewDecoder(res.Body).Decode(&o))
+				ids <- o.ID
+			})
+		}
+		wg.Wait()
+		close(ids)
+
+		first := <-ids
+		assert.NotEmpty(t, first)
+		for id := range ids {
+			assert.Equal(t, first, id)
+		}
+
+		var p orders.Page
+		res := do(t, addr, http.MethodGet, "/order", "apitest", nil, &p)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, p.Orders, 1)
+	})
+}
+
+func TestGetOrder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("order exists", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		want := placeOrder(t, addr, "apitest", goodOrder())
+
+		req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/order/"+want.ID, nil)
+		require.NoError(t, err)
+		req.Header.Set(server.APIKeyHeader, "apitest")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var got orders.Order
+		err = json.NewDecoder(res.Body).Decode(&got)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("order missing", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/order/9999", nil)
+		require.NoError(t, err)
+		req.Header.Set(server.APIKeyHeader, "apitest")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		var se server.ServerError
+		err = json.NewDecoder(res.Body).Decode(&se)
+		require.NoError(t, err)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeNotFound, Message: "order 9999 not found"}, se)
+	})
+
+	t.Run("other customer's order", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		o := placeOrder(t, addr, "apitest", goodOrder())
+		var se server.ServerError
+		assert.Equal(t, http.StatusNotFound, do(t, addr, http.MethodGet, "/order/"+o.ID, "apitest2", nil, &se).StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeNotFound, Message: "order " + o.ID + " not found"}, se)
+
+		var got orders.Order
+		assert.Equal(t, http.StatusOK, do(t, addr, http.MethodGet, "/order/"+o.ID, "staff", nil, &got).StatusCode, "staff can read any order")
+		assert.Equal(t, o, got)
+	})
+
+	t.Run("no token", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		res, err := http.Get("http://" + addr + "/order/9999")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("token missing required scope", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/order/9999", nil)
+		require.NoError(t, err)
+		req.Header.Set(server.APIKeyHeader, "noscope")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		b, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Len(t, b, 0)
+	})
+}
+
+func TestListOrders(t *testing.T) {
+	t.Parallel()
+
+	t.Run("pages through own orders", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		want := map[string]orders.Order{}
+		for range 3 {
+			o := placeOrder(t, addr, "apitest", goodOrder())
+			want[o.ID] = o
+		}
+		placeOrder(t, addr, "apitest2", goodOrder())
+
+		got := map[string]orders.Order{}
+		cursor := ""
+		for range 2 {
+			var p orders.Page
+			res := do(t, addr, http.MethodGet, "/order?limit=2&cursor="+cursor, "apitest", nil, &p)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			for _, o := range p.Orders {
+				got[o.ID] = o
+			}
+			cursor = p.NextCursor
+		}
+		assert.Empty(t, cursor)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("filters by created range and status", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		o := placeOrder(t, addr, "apitest", goodOrder())
+
+		var p orders.Page
+		res := do(t, addr, http.MethodGet, "/order?status=placed&createdFrom="+o.CreatedAt.Add(-time.Second).Format(time.RFC3339), "apitest", nil, &p)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []orders.Order{o}, p.Orders)
+
+		p = orders.Page{}
+		res = do(t, addr, http.MethodGet, "/order?createdFrom="+o.CreatedAt.Add(time.Hour).Format(time.RFC3339), "apitest", nil, &p)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Empty(t, p.Orders)
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		var se server.ServerError
+		res := do(t, addr, http.MethodGet, "/order?limit=1000", "apitest", nil, &se)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeValidation, Message: "limit must be between 1 and 100"}, se)
+	})
+
+	t.Run("token missing required scope", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		res := do(t, addr, http.MethodGet, "/order", "noscope", nil, nil)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+}
+
+func TestTransitionOrder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("full lifecycle", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		o := placeOrder(t, addr, "apitest", goodOrder())
+		steps := []struct {
+			action string
+			want   orders.Status
+		}{
+			{"accept", orders.StatusAccepted},
+			{"prepare", orders.StatusPreparing},
+			{"ready", orders.StatusReady},
+			{"complete", orders.StatusCompleted},
+		}
+		for _, step := range steps {
+			res := do(t, addr, http.MethodPost, "/order/"+o.ID+"/"+step.action, "staff", nil, &o)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, step.want, o.Status)
+		}
+		require.Len(t, o.History, len(steps))
+		assert.Equal(t, orders.StatusPlaced, o.History[0].From)
+		assert.Equal(t, "test-staff", o.History[0].Actor)
+	})
+
+	t.Run("illegal transition", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		o := placeOrder(t, addr, "apitest", goodOrder())
+		var se server.ServerError
+		res := do(t, addr, http.MethodPost, "/order/"+o.ID+"/complete", "staff", nil, &se)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeConstraint, Message: "order cannot move from placed to completed"}, se)
 	})
+
+	t.Run("order missing", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		res := do(t, addr, http.MethodPost, "/order/9999/accept", "staff", nil, nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("token missing required scope", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		o := placeOrder(t, addr, "apitest", goodOrder())
+		res := do(t, addr, http.MethodPost, "/order/"+o.ID+"/accept", "apitest", nil, nil)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+}
+
+func TestCancelOrder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("customer cancels own order", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		o := placeOrder(t, addr, "apitest", goodOrder())
+		res := do(t, addr, http.MethodPost, "/order/"+o.ID+"/cancel", "apitest", orders.CancelReq{Reason: orders.CancelCustomerRequest}, &o)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, orders.StatusCancelled, o.Status)
+		require.NotNil(t, o.Cancellation)
+		assert.Equal(t, orders.CancelCustomerRequest, o.Cancellation.Reason)
+		assert.Equal(t, "test-client", o.Cancellation.By)
+	})
+
+	t.Run("customer cannot cancel another customers order", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		o := placeOrder(t, addr, "apitest2", goodOrder())
+		res := do(t, addr, http.MethodPost, "/order/"+o.ID+"/cancel", "apitest", orders.CancelReq{Reason: orders.CancelCustomerRequest}, nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("staff cancels any order", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		o := placeOrder(t, addr, "apitest", goodOrder())
+		res := do(t, addr, http.MethodPost, "/order/"+o.ID+"/cancel", "staff", orders.CancelReq{Reason: orders.CancelOutOfStock, Note: "no waffles"}, &o)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "test-staff", o.Cancellation.By)
+		assert.Equal(t, "no waffles", o.Cancellation.Note)
+	})
+
+	t.Run("already preparing", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		o := placeOrder(t, addr, "apitest", goodOrder())
+		for _, action := range []string{"accept", "prepare"} {
+			require.Equal(t, http.StatusOK, do(t, addr, http.MethodPost, "/order/"+o.ID+"/"+action, "staff", nil, nil).StatusCode)
+		}
+
+		var se server.ServerError
+		res := do(t, addr, http.MethodPost, "/order/"+o.ID+"/cancel", "apitest", orders.CancelReq{Reason: orders.CancelCustomerRequest}, &se)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeConstraint, Message: "order can no longer be cancelled once preparing"}, se)
+	})
+
+	t.Run("unknown reason", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		o := placeOrder(t, addr, "apitest", goodOrder())
+		var se server.ServerError
+		res := do(t, addr, http.MethodPost, "/order/"+o.ID+"/cancel", "apitest", orders.CancelReq{Reason: "bored"}, &se)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeConstraint, Message: `unknown reason "bored"`}, se)
+	})
+
+	t.Run("token missing required scope", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		res := do(t, addr, http.MethodPost, "/order/9999/cancel", "noscope", orders.CancelReq{Reason: orders.CancelCustomerRequest}, nil)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+}
+
+func TestWebhooks(t *testing.T) {
+	t.Parallel()
+
+	t.Run("signed events delivered to subscriber", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t, "-webhook-interval", "10ms")
+		defer noErr(t, close)
+
+		var (
+			mu       sync.Mutex
+			received []orders.Event
+			secret   string
+		)
+		rc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			body, _ := io.ReadAll(r.Body)
+			if err := webhooks.Verify(secret, r.Header.Get(webhooks.HeaderTimestamp), r.Header.Get(webhooks.HeaderSignature), body); err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			var e orders.Event
+			if err := json.Unmarshal(body, &e); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			received = append(received, e)
+		}))
+		defer rc.Close()
+
+		var sub webhooks.Subscription
+		res := do(t, addr, http.MethodPost, "/webhook", "admin", map[string]string{"url": rc.URL}, &sub)
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+		require.NotEmpty(t, sub.Secret)
+		mu.Lock()
+		secret = sub.Secret
+		mu.Unlock()
+
+		o := placeOrder(t, addr, "apitest", goodOrder())
+		res = do(t, addr, http.MethodPost, "/order/"+o.ID+"/accept", "staff", nil, nil)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		assert.EventuallyWithT(t, func(c *assert.CollectT) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !assert.Len(c, received, 2) {
+				return
+			}
+			types := []orders.EventType{received[0].Type, received[1].Type}
+			assert.ElementsMatch(c, []orders.EventType{orders.EventCreated, orders.EventStatusChanged}, types)
+			assert.Equal(c, o.ID, received[0].Order.ID)
+		}, 5*time.Second, 10*time.Millisecond)
+
+		var subs []webhooks.Subscription
+		res = do(t, addr, http.MethodGet, "/webhook", "admin", nil, &subs)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Len(t, subs, 1)
+		assert.Empty(t, subs[0].Secret, "secret is never listed")
+
+		res = do(t, addr, http.MethodDelete, "/webhook/"+sub.ID, "admin", nil, nil)
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+		res = do(t, addr, http.MethodDelete, "/webhook/"+sub.ID, "admin", nil, nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("invalid subscription", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		var se server.ServerError
+		res := do(t, addr, http.MethodPost, "/webhook", "admin", map[string]any{"url": "nope", "events": []string{"order.eaten"}}, &se)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeConstraint, Message: "url must be an absolute http or https url\nunknown event \"order.eaten\""}, se)
+	})
+
+	t.Run("dead letters are listed", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		var dead []webhooks.Delivery
+		res := do(t, addr, http.MethodGet, "/webhook/deadletter", "admin", nil, &dead)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Empty(t, dead)
+	})
+
+	t.Run("token missing required scope", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		res := do(t, addr, http.MethodGet, "/webhook", "staff", nil, nil)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+}
+
+func TestDurableOrders(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	addr, close := startServer(t, "-orders-dir", dir)
+	o := placeOrder(t, addr, "apitest", goodOrder())
+	noErr(t, close)
+
+	addr, close = startServer(t, "-orders-dir", dir)
+	defer noErr(t, close)
+	var got orders.Order
+	res := do(t, addr, http.MethodGet, "/order/"+o.ID, "apitest", nil, &got)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, o, got)
+}
+
+func TestProductsFile(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "products.csv")
+	require.NoError(t, os.WriteFile(path, []byte("id,name,category,price\n1,Waffle,Waffle,6.50\n"), 0o644))
+	addr, close := startServer(t, "-products", path, "-products-reload-interval", "10ms")
+	defer noErr(t, close)
+
+	getPrice := func(t require.TestingT) money.Money {
+		res, err := http.Get("http://" + addr + "/product/1")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var p products.Product
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&p))
+		return p.Price
+	}
+	assert.Equal(t, money.New(650, "AUD"), getPrice(t))
+
+	require.NoError(t, os.WriteFile(path, []byte("id,name,category,price\n1,Waffle,Waffle,7.25\n"), 0o644))
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.Equal(c, money.New(725, "AUD"), getPrice(c))
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestCategories(t *testing.T) {
+	t.Parallel()
+	addr, close := startServer(t)
+	defer noErr(t, close)
+
+	sample := products.NewSlice(products.SampleData)
+	t.Run("lists categories", func(t *testing.T) {
+		want, err := categories.Parse(categories.SampleData)
+		require.NoError(t, err)
+		var cs []categories.Category
+		require.Equal(t, http.StatusOK, do(t, addr, http.MethodGet, "/category", "", nil, &cs).StatusCode)
+		assert.Equal(t, want, cs)
+	})
+
+	t.Run("category products", func(t *testing.T) {
+		var ps []products.Product
+		require.Equal(t, http.StatusOK, do(t, addr, http.MethodGet, "/category/Pie/product", "", nil, &ps).StatusCode)
+		assert.Equal(t, []products.Product{sample[5]}, ps)
+		require.Equal(t, http.StatusOK, do(t, addr, http.MethodGet, "/category/"+url.PathEscape("Crème Brûlée")+"/product", "", nil, &ps).StatusCode)
+		assert.Equal(t, []products.Product{sample[1]}, ps)
+		assert.Equal(t, http.StatusNotFound, do(t, addr, http.MethodGet, "/category/Soup/product", "", nil, nil).StatusCode)
+	})
+
+	t.Run("products must be in a category", func(t *testing.T) {
+		dir := t.TempDir()
+		cats := filepath.Join(dir, "categories.json")
+		require.NoError(t, os.WriteFile(cats, []byte(`[{"id":"pie","name":"Pies"}]`), 0o644))
+		err := run(t.Context(), []string{"-categories", cats})
+		assert.ErrorContains(t, err, "product[0] category Waffle not found")
+
+		ps := filepath.Join(dir, "products.csv")
+		require.NoError(t, os.WriteFile(ps, []byte("id,name,category,price\n1,Waffle,Waffle,6.50\n"), 0o644))
+		err = run(t.Context(), []string{"-categories", cats, "-products", ps})
+		assert.ErrorContains(t, err, "product[0] category Waffle not found")
+	})
+}
+
+func TestLocations(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "city.csv"), []byte("id,name,category,price\n1,Waffle,Waffle,8.00\n"), 0o644))
+	addr, close := startServer(t, "-locations", dir)
+	defer noErr(t, close)
+
+	t.Run("lists locations", func(t *testing.T) {
+		var ls []locations.Location
+		require.Equal(t, http.StatusOK, do(t, addr, http.MethodGet, "/location", "", nil, &ls).StatusCode)
+		assert.Equal(t, []locations.Location{{ID: "city", Name: "city"}, {ID: "default", Name: "Default"}}, ls)
+	})
+
+	t.Run("location products", func(t *testing.T) {
+		var ps []products.Product
+		require.Equal(t, http.StatusOK, do(t, addr, http.MethodGet, "/location/city/product", "", nil, &ps).StatusCode)
+		assert.Equal(t, []products.Product{{ID: "1", Name: "Waffle", Category: "Waffle", CategoryID: "waffle", Price: money.New(800, "AUD")}}, ps)
+
+		var p products.Product
+		require.Equal(t, http.StatusOK, do(t, addr, http.MethodGet, "/location/city/product/1", "", nil, &p).StatusCode)
+		assert.Equal(t, money.New(800, "AUD"), p.Price)
+		assert.Equal(t, http.StatusNotFound, do(t, addr, http.MethodGet, "/location/city/product/2", "", nil, nil).StatusCode)
+		assert.Equal(t, http.StatusNotFound, do(t, addr, http.MethodGet, "/location/moon/product", "", nil, nil).StatusCode)
+	})
+
+	t.Run("default location serves legacy routes", func(t *testing.T) {
+		var legacy, scoped []products.Product
+		require.Equal(t, http.StatusOK, do(t, addr, http.MethodGet, "/product", "", nil, &legacy).StatusCode)
+		require.Equal(t, http.StatusOK, do(t, addr, http.MethodGet, "/location/default/product", "", nil, &scoped).StatusCode)
+		assert.Equal(t, legacy, scoped)
+	})
+
+	t.Run("orders priced from their location", func(t *testing.T) {
+		or := goodOrder()
+		or.LocationID = "city"
+		o := placeOrder(t, addr, "apitest", or)
+		assert.Equal(t, "city", o.LocationID)
+		assert.Equal(t, money.New(800, "AUD"), o.Total)
+
+		o = placeOrder(t, addr, "apitest", goodOrder())
+		assert.Empty(t, o.LocationID)
+		assert.Equal(t, money.New(650, "AUD"), o.Total)
+
+		or.Items[0].ProductID = "2"
+		res := do(t, addr, http.MethodPost, "/order", "apitest", or, nil)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	})
+}
+
+func TestSetStock(t *testing.T) {
+	t.Parallel()
+
+	t.Run("orders limited by stock", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		var l inventory.Level
+		res := do(t, addr, http.MethodPut, "/product/1/stock", "admin", `{"available":1,"lowStock":1}`, &l)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, inventory.Level{ProductID: "1", Available: 1, LowStock: 1}, l)
+
+		or := goodOrder()
+		or.Items[0].Quantity = 2
+		var se server.ServerError
+		res = do(t, addr, http.MethodPost, "/order", "apitest", or, &se)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeConstraint, Message: "item[0] only 1 of product 1 in stock"}, se)
+
+		placeOrder(t, addr, "apitest", goodOrder())
+	})
+
+	t.Run("invalid stock level", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		var se server.ServerError
+		res := do(t, addr, http.MethodPut, "/product/1/stock", "admin", `{"available":-1}`, &se)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeConstraint, Message: "available cannot be less than zero"}, se)
+	})
+
+	t.Run("product missing", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		res := do(t, addr, http.MethodPut, "/product/9001/stock", "admin", `{"available":1}`, nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("location product", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "kiosk.csv"), []byte("id,name,category,price\n20,Kiosk Waffle,Waffle,9.00\n"), 0o644))
+		addr, close := startServer(t, "-locations", dir)
+		defer noErr(t, close)
+
+		var l inventory.Level
+		res := do(t, addr, http.MethodPut, "/location/kiosk/product/20/stock", "admin", `{"available":1}`, &l)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, inventory.Level{ProductID: "20", Available: 1}, l)
+
+		or := goodOrder()
+		or.LocationID = "kiosk"
+		or.Items[0] = orders.OrderItem{ProductID: "20", Quantity: 2}
+		var se server.ServerError
+		res = do(t, addr, http.MethodPost, "/order", "apitest", or, &se)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeConstraint, Message: "item[0] only 1 of product 20 in stock"}, se)
+
+		res = do(t, addr, http.MethodPut, "/product/20/stock", "admin", `{"available":1}`, nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode, "not sold at the default location")
+		res = do(t, addr, http.MethodPut, "/location/moon/product/1/stock", "admin", `{"available":1}`, nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode, "no location")
+	})
+
+	t.Run("requires authentication", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		res := do(t, addr, http.MethodPut, "/product/1/stock", "", `{"available":1}`, nil)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("token missing required scope", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		res := do(t, addr, http.MethodPut, "/product/1/stock", "staff", `{"available":1}`, nil)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+}
+
+func TestProductAdmin(t *testing.T) {
+	t.Parallel()
+
+	lemon := products.Product{ID: "10", Name: "Lemon Tart", Category: "Pie", CategoryID: "pie", Price: money.New(600, "AUD")}
+
+	t.Run("create, edit and retire", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		var p products.Product
+		require.Equal(t, http.StatusCreated, do(t, addr, http.MethodPost, "/product", "admin", `{"id":"10","name":"Lemon Tart","category":"Pie","price":6}`, &p).StatusCode)
+		assert.Equal(t, lemon, p)
+		require.Equal(t, http.StatusOK, do(t, addr, http.MethodGet, "/product/10", "", "", &p).StatusCode)
+		assert.Equal(t, lemon, p)
+
+		require.Equal(t, http.StatusOK, do(t, addr, http.MethodPut, "/product/10", "admin", `{"name":"Lime Tart","category":"Pie","price":6.5}`, &p).StatusCode)
+		assert.Equal(t, products.Product{ID: "10", Name: "Lime Tart", Category: "Pie", CategoryID: "pie", Price: money.New(650, "AUD")}, p)
+
+		require.Equal(t, http.StatusOK, do(t, addr, http.MethodPatch, "/product/10", "admin", `{"price":7}`, &p).StatusCode)
+		assert.Equal(t, products.Product{ID: "10", Name: "Lime Tart", Category: "Pie", CategoryID: "pie", Price: money.New(700, "AUD")}, p)
+
+		require.Equal(t, http.StatusNoContent, do(t, addr, http.MethodDelete, "/product/10", "admin", "", nil).StatusCode)
+		assert.Equal(t, http.StatusNotFound, do(t, addr, http.MethodGet, "/product/10", "", "", &p).StatusCode)
+		assert.Equal(t, http.StatusNotFound, do(t, addr, http.MethodDelete, "/product/10", "admin", "", nil).StatusCode)
+		assert.Equal(t, http.StatusUnprocessableEntity, do(t, addr, http.MethodPost, "/product", "admin", `{"id":"10","name":"Lemon Tart","category":"Pie","price":6}`, nil).StatusCode, "retired ids aren't reused")
+	})
+
+	t.Run("retired products stay on orders", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		placed := placeOrder(t, addr, "apitest", goodOrder())
+		var p products.Product
+		require.Equal(t, http.StatusOK, do(t, addr, http.MethodPatch, "/product/1", "admin", `{"name":"Waffle","price":9}`, &p).StatusCode)
+		require.Equal(t, http.StatusNoContent, do(t, addr, http.MethodDelete, "/product/1", "admin", "", nil).StatusCode)
+
+		var got orders.Order
+		require.Equal(t, http.StatusOK, do(t, addr, http.MethodGet, "/order/"+placed.ID, "apitest", "", &got).StatusCode)
+		assert.Equal(t, placed, got, "order keeps the product as it was when placed")
+
+		var se server.ServerError
+		assert.Equal(t, http.StatusUnprocessableEntity, do(t, addr, http.MethodPost, "/order", "apitest", string(goodOrderBytes(t)), &se).StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeConstraint, Message: "invalid product specified"}, se)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		var se server.ServerError
+		assert.Equal(t, http.StatusBadRequest, do(t, addr, http.MethodPost, "/product", "admin", `{"id":"10","category":"Soup"}`, &se).StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeValidation, Message: "name is required\nprice must be greater than zero\ncategory Soup not found"}, se)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, do(t, addr, http.MethodPost, "/product", "admin", `{"id":"1","name":"Waffle","category":"Waffle","price":1}`, &se).StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeConstraint, Message: "product 1 already exists"}, se)
+
+		assert.Equal(t, http.StatusBadRequest, do(t, addr, http.MethodPut, "/product/1", "admin", `{"id":"2","name":"Waffle","category":"Waffle","price":1}`, &se).StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeValidation, Message: "id 2 doesn't match product 1"}, se)
+
+		assert.Equal(t, http.StatusBadRequest, do(t, addr, http.MethodPatch, "/product/1", "admin", `{}`, &se).StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeValidation, Message: "at least one of name, category or price is required"}, se)
+
+		assert.Equal(t, http.StatusBadRequest, do(t, addr, http.MethodPatch, "/product/1", "admin", `{"price":0}`, &se).StatusCode)
+		assert.Equal(t, server.ServerError{Code: server.ErrCodeValidation, Message: "price must be greater than zero"}, se)
+
+		assert.Equal(t, http.StatusNotFound, do(t, addr, http.MethodPatch, "/product/9001", "admin", `{"price":1}`, &se).StatusCode)
+	})
+
+	t.Run("requires product:write", func(t *testing.T) {
+		t.Parallel()
+		addr, close := startServer(t)
+		defer noErr(t, close)
+
+		body := `{"id":"10","name":"Lemon Tart","category":"Pie","price":6}`
+		assert.Equal(t, http.StatusUnauthorized, do(t, addr, http.MethodPost, "/product", "", body, nil).StatusCode)
+		assert.Equal(t, http.StatusForbidden, do(t, addr, http.MethodPost, "/product", "staff", body, nil).StatusCode)
+		assert.Equal(t, http.StatusForbidden, do(t, addr, http.MethodDelete, "/product/1", "apitest", "", nil).StatusCode)
+	})
+
+	t.Run("catalogue files are read only", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "products.csv")
+		require.NoError(t, os.WriteFile(path, []byte("id,name,category,price\n1,Waffle,Waffle,6.50\n"), 0o644))
+		addr, close := startServer(t, "-products", path)
+		defer noErr(t, close)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, do(t, addr, http.MethodDelete, "/product/1", "admin", "", nil).StatusCode)
+	})
+}
+
+func TestConditionalGet(t *testing.T) {
+	t.Parallel()
+	addr, close := startServer(t)
+	defer noErr(t, close)
+
+	// get gets path with the conditional request headers in h.
+	get := func(t *testing.T, path string, h http.Header) (*http.Response, []byte) {
+		t.Helper()
+		req := newRequest(t, addr, http.MethodGet, path, "", nil)
+		maps.Copy(req.Header, h)
+		res := send(t, req, nil)
+		b, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, b
+	}
+
+	for _, path := range []string{"/product", "/product/1", "/category/Waffle/product?sort=price"} {
+		t.Run(path, func(t *testing.T) {
+			res, _ := get(t, path, nil)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			etag, modified := res.Header.Get("ETag"), res.Header.Get("Last-Modified")
+			assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag, "strong etag")
+			assert.NotEmpty(t, modified)
+			assert.Equal(t, server.CatalogueCacheControl, res.Header.Get("Cache-Control"))
+
+			for name, h := range map[string]http.Header{
+				"matching etag":      {"If-None-Match": {etag}},
+				"weak etag":          {"If-None-Match": {`"other", W/` + etag}},
+				"any":                {"If-None-Match": {"*"}},
+				"not modified since": {"If-Modified-Since": {modified}},
+			} {
+				res, b := get(t, path, h)
+				assert.Equal(t, http.StatusNotModified, res.StatusCode, name)
+				assert.Empty(t, b, name)
+				assert.Equal(t, etag, res.Header.Get("ETag"), name)
+			}
+
+			res, _ = get(t, path, http.Header{"If-None-Match": {`"other"`}, "If-Modified-Since": {modified}})
+			assert.Equal(t, http.StatusOK, res.StatusCode, "If-None-Match takes precedence")
+		})
+	}
+
+	t.Run("changes exactly when the catalogue does", func(t *testing.T) {
+		res, _ := get(t, "/product", nil)
+		etag := res.Header.Get("ETag")
+		other, _ := get(t, "/product?pageSize=2", nil)
+		assert.NotEqual(t, etag, other.Header.Get("ETag"), "each query has its own etag")
+
+		require.Equal(t, http.StatusOK, do(t, addr, http.MethodPatch, "/product/2", "admin", `{"price":7}`, nil).StatusCode)
+		res, _ = get(t, "/product", http.Header{"If-None-Match": {etag}})
+		assert.Equal(t, http.StatusNotModified, res.StatusCode, "same products")
+
+		require.Equal(t, http.StatusOK, do(t, addr, http.MethodPatch, "/product/2", "admin", `{"price":7.5}`, nil).StatusCode)
+		res, b := get(t, "/product", http.Header{"If-None-Match": {etag}})
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.NotEqual(t, etag, res.Header.Get("ETag"))
+		var ps []products.Product
+		require.NoError(t, json.Unmarshal(b, &ps))
+		assert.Equal(t, money.New(750, "AUD"), ps[1].Price)
+	})
+
+	t.Run("errors aren't cacheable", func(t *testing.T) {
+		res, _ := get(t, "/product?sort=colour", nil)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Empty(t, res.Header.Get("ETag"))
+		res, _ = get(t, "/product/9001", nil)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		assert.Empty(t, res.Header.Get("ETag"))
+	})
+
+	t.Run("orders are private", func(t *testing.T) {
+		res, _ := get(t, "/order", http.Header{server.APIKeyHeader: {"apitest"}})
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, server.PrivateCacheControl, res.Header.Get("Cache-Control"))
+	})
+}
+
+// placeOrder creates or against the server at addr using apiKey failing the test if it can't.
+func placeOrder(t *testing.T, addr, apiKey string, or orders.OrderReq) orders.Order {
+	t.Helper()
+	var o orders.Order
+	res := do(t, addr, http.MethodPost, "/order", apiKey, or, &o)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	return o
+}
+
+// newRequest returns a method request for path on the server at addr, made with apiKey unless
+// it's empty. A string body is sent as is and any other body but nil is sent JSON encoded.
+func newRequest(t *testing.T, addr, method, path, apiKey string, body any) *http.Request {
+	t.Helper()
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		r = strings.NewReader(b)
+	default:
+		j, err := json.Marshal(b)
+		require.NoError(t, err)
+		r = bytes.NewReader(j)
+	}
+	req, err := http.NewRequest(method, "http://"+addr+path, r)
+	require.NoError(t, err)
+	if apiKey != "" {
+		req.Header.Set(server.APIKeyHeader, apiKey)
+	}
+	return req
+}
+
+// send sends req decoding the response body into v unless v is nil or there's no body. The body
+// is left readable on the response for tests that check it themselves.
+func send(t *testing.T, req *http.Request, v any) *http.Response {
+	t.Helper()
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	res.Body = io.NopCloser(bytes.NewReader(b))
+	if v != nil && len(b) > 0 {
+		require.NoError(t, json.Unmarshal(b, v))
+	}
+	return res
+}
+
+// do sends a request made by [newRequest] decoding the response into v, see [send].
+func do(t *testing.T, addr, method, path, apiKey string, body, v any) *http.Response {
+	t.Helper()
+	return send(t, newRequest(t, addr, method, path, apiKey, body), v)
 }
 
 func goodOrder() orders.OrderReq {
@@ -321,11 +1389,12 @@ func goodOrderBytes(t *testing.T) []byte {
 	return b
 }
 
-func startServer(t *testing.T) (addr string, close func() error) {
+// startServer runs the application on a random port with any extra command line args.
+func startServer(t *testing.T, args ...string) (addr string, close func() error) {
 	t.Helper()
 	addr, err := ports.Random(t.Context())
 	require.NoError(t, err)
-	err, close = grun.Start(t.Context(), toRun([]string{"-a", addr}), exp.Poller(addr, exp.PollHTTP))
+	err, close = grun.Start(t.Context(), toRun(append([]string{"-a", addr}, args...)), exp.Poller(addr, exp.PollHTTP))
 	require.NoError(t, err)
 	return addr, close
 }
@@ -1,19 +1,171 @@
-// package coupons contins
+// package coupons contains the coupon data model and stores used to look coupons up.
 package coupons
 
 import (
 	"bufio"
+	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"io"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/money"
 )
 
-//go:embed data
+// DB is the embedded coupon database in the format read by [NewPacked], built by tools/coupons.
+//
+//go:embed data.bin
 var DB string
 
+// DefaultPercent is the discount given by the [Default] rule.
+const DefaultPercent = 10
+
+// Kind is the type of discount a [Coupon] gives.
+type Kind string
+
+const (
+	// KindPercentage takes Percent off the price of every eligible item.
+	KindPercentage Kind = "percentage"
+	// KindFixed takes Amount off the price of eligible items.
+	KindFixed Kind = "fixed"
+	// KindBuyXGetY gives Get units of an eligible item free for every Buy units paid for.
+	KindBuyXGetY Kind = "buyXGetY"
+	// KindFreeItem gives a single unit of FreeProductID free when it's in the order.
+	KindFreeItem Kind = "freeItem"
+)
+
+// Coupon is a promo code and the rule describing the discount it gives.
+type Coupon struct {
+	Code string `json:"code"`
+	Kind Kind   `json:"kind"`
+	// Percent off eligible items for [KindPercentage].
+	Percent float64 `json:"percent,omitempty"`
+	// Amount off eligible items for [KindFixed].
+	Amount money.Money `json:"amount,omitzero"`
+	// Buy and Get describe the deal given by [KindBuyXGetY].
+	Buy int `json:"buy,omitempty"`
+	Get int `json:"get,omitempty"`
+	// FreeProductID is the product given away by [KindFreeItem].
+	FreeProductID string `json:"freeProductId,omitempty"`
+	// MinSpend is the order subtotal required before the coupon applies.
+	MinSpend money.Money `json:"minSpend,omitzero"`
+	// ProductIDs and Categories restrict which items the coupon applies to. When both are
+	// empty every item is eligible.
+	ProductIDs []string `json:"productIds,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	Limits
+}
+
+// Limits restrict how many times a [Coupon] can be used, zero for unlimited.
+type Limits struct {
+	// MaxRedemptions is the number of times the coupon can be used in total.
+	MaxRedemptions int `json:"maxRedemptions,omitempty"`
+	// MaxPerAPIKey is the number of times the coupon can be used by a single API key.
+	MaxPerAPIKey int `json:"maxPerApiKey,omitempty"`
+	// MaxPerCustomer is the number of times the coupon can be used by a single customer. Orders
+	// must identify their customer to use a coupon with a per customer limit.
+	MaxPerCustomer int `json:"maxPerCustomer,omitempty"`
+}
+
+// Default returns the rule given to codes that don't have a rule of their own.
+func Default(code string) Coupon {
+	return Coupon{
+		Code:    code,
+		Kind:    KindPercentage,
+		Percent: DefaultPercent,
+	}
+}
+
+// Validate checks whether c is well formed.
+func (c Coupon) Validate() error {
+	ve := []error{}
+	if c.Code == "" {
+		ve = append(ve, errors.New("code is required"))
+	}
+	switch c.Kind {
+	case KindPercentage:
+		if c.Percent <= 0 || c.Percent > 100 {
+			ve = append(ve, errors.New("percent must be greater than zero and at most 100"))
+		}
+	case KindFixed:
+		if c.Amount.Amount <= 0 {
+			ve = append(ve, errors.New("amount must be greater than zero"))
+		}
+	case KindBuyXGetY:
+		if c.Buy < 1 || c.Get < 1 {
+			ve = append(ve, errors.New("buy and get must be greater than zero"))
+		}
+	case KindFreeItem:
+		if c.FreeProductID == "" {
+			ve = append(ve, errors.New("freeProductId is required"))
+		}
+	default:
+		ve = append(ve, fmt.Errorf("unknown kind %q", c.Kind))
+	}
+	if err := c.Limits.Validate(); err != nil {
+		ve = append(ve, err)
+	}
+	if c.MinSpend.Amount < 0 {
+		ve = append(ve, errors.New("minSpend cannot be less than zero"))
+	}
+	if len(ve) > 0 {
+		return errors.Join(ve...)
+	}
+	return nil
+}
+
+// Validate checks whether l is well formed.
+func (l Limits) Validate() error {
+	ve := []error{}
+	if l.MaxRedemptions < 0 {
+		ve = append(ve, errors.New("maxRedemptions cannot be less than zero"))
+	}
+	if l.MaxPerAPIKey < 0 {
+		ve = append(ve, errors.New("maxPerApiKey cannot be less than zero"))
+	}
+	if l.MaxPerCustomer < 0 {
+		ve = append(ve, errors.New("maxPerCustomer cannot be less than zero"))
+	}
+	if len(ve) > 0 {
+		return errors.Join(ve...)
+	}
+	return nil
+}
+
+// Limited reports whether c can only be used a limited number of times.
+func (c Coupon) Limited() bool {
+	return c.MaxRedemptions > 0 || c.MaxPerAPIKey > 0 || c.MaxPerCustomer > 0
+}
+
+// Eligible reports whether an item with the given product ID and category can be discounted by c.
+func (c Coupon) Eligible(productID, category string) bool {
+	if len(c.ProductIDs) == 0 && len(c.Categories) == 0 {
+		return true
+	}
+	for _, v := range c.ProductIDs {
+		if v == productID {
+			return true
+		}
+	}
+	for _, v := range c.Categories {
+		if v == category {
+			return true
+		}
+	}
+	return false
+}
+
 // Store is the interface for interacting with coupon data.
+//
+// Every method returns ctx.Err() once ctx is done. Implementations are checked with storetest.Coupons.
 type Store interface {
-	Has(code string) bool
+	// Lookup returns the coupon for code or an [apperr.CodeNotFound] error if there isn't one.
+	Lookup(ctx context.Context, code string) (Coupon, error)
+}
+
+func notFound(code string) error {
+	return apperr.NewError(apperr.CodeNotFound, fmt.Errorf("coupon %s not found", code))
 }
 
 var _ Store = Mem{}
@@ -33,11 +185,68 @@ func NewMem(r io.Reader) (Store, error) {
 	return m, nil
 }
 
-// Mem is a [Store] implemented on an in memory map.
+// Mem is a [Store] implemented on an in memory map. Every code is given the [Default] rule.
 type Mem map[string]struct{}
 
-// Has implements [Store.Has]
-func (m Mem) Has(code string) bool {
-	_, has := m[code]
-	return has
+// Lookup implements [Store.Lookup].
+func (m Mem) Lookup(ctx context.Context, code string) (Coupon, error) {
+	if err := ctx.Err(); err != nil {
+		return Coupon{}, err
+	}
+	if _, has := m[code]; !has {
+		return Coupon{}, notFound(code)
+	}
+	return Default(code), nil
+}
+
+// NewRules creates a [Rules] store from cs validating each coupon.
+func NewRules(cs ...Coupon) (Rules, error) {
+	r := Rules{}
+	for _, c := range cs {
+		if err := c.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid coupon %s: %w", c.Code, err)
+		}
+		r[c.Code] = c
+	}
+	return r, nil
+}
+
+// WithLimits returns a [Store] that gives every coupon from s without limits of its own the
+// limits l.
+func WithLimits(s Store, l Limits) Store {
+	return limited{next: s, limits: l}
+}
+
+type limited struct {
+	next   Store
+	limits Limits
+}
+
+// Lookup implements [Store.Lookup].
+func (l limited) Lookup(ctx context.Context, code string) (Coupon, error) {
+	c, err := l.next.Lookup(ctx, code)
+	if err != nil {
+		return Coupon{}, err
+	}
+	if !c.Limited() {
+		c.Limits = l.limits
+	}
+	return c, nil
+}
+
+var _ Store = Rules{}
+
+// Rules is a [Store] of coupons that each have their own rule, keyed by code.
+type Rules map[string]Coupon
+
+// Lookup implements [Store.Lookup].
+func (r Rules) Lookup(ctx context.Context, code string) (Coupon, error) {
+	if err := ctx.Err(); err != nil {
+		return Coupon{}, err
+	}
+	c, has := r[code]
+	if !has {
+		return Coupon{}, notFound(code)
+	}
+	return c, nil
 }
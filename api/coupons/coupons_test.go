@@ -4,13 +4,68 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/money"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func TestMem_Has(t *testing.T) {
-	m, err := NewMem(strings.NewReader(DB))
+// dbText returns the codes in [DB] in the newline separated format read by [NewMem].
+func dbText(t testing.TB) string {
+	t.Helper()
+	p, err := NewPacked(DB)
 	require.NoError(t, err)
-	assert.True(t, m.Has("OVER9000"))
-	assert.False(t, m.Has("UNDER9000"))
+	var b strings.Builder
+	for v := range p.All() {
+		b.WriteString(v + "\n")
+	}
+	return b.String()
+}
+
+func TestMem_Lookup(t *testing.T) {
+	m, err := NewMem(strings.NewReader(dbText(t)))
+	require.NoError(t, err)
+
+	c, err := m.Lookup(t.Context(), "OVER9000")
+	require.NoError(t, err)
+	assert.Equal(t, Default("OVER9000"), c)
+
+	_, err = m.Lookup(t.Context(), "UNDER9000")
+	var ae apperr.Error
+	require.ErrorAs(t, err, &ae, "err must be an app error")
+	assert.Equal(t, apperr.CodeNotFound, ae.Code)
+	assert.ErrorContains(t, err, "coupon UNDER9000 not found")
+}
+
+func TestNewRules(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid", func(t *testing.T) {
+		t.Parallel()
+		want := Coupon{Code: "FIVEOFF", Kind: KindFixed, Amount: money.New(500, "AUD"), MinSpend: money.New(2000, "AUD")}
+		r, err := NewRules(want)
+		require.NoError(t, err)
+
+		got, err := r.Lookup(t.Context(), "FIVEOFF")
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewRules(Coupon{Code: "BAD", Kind: KindBuyXGetY, MinSpend: money.New(-100, "AUD")})
+		assert.ErrorContains(t, err, "invalid coupon BAD")
+		assert.ErrorContains(t, err, "buy and get must be greater than zero")
+		assert.ErrorContains(t, err, "minSpend cannot be less than zero")
+	})
+}
+
+func TestCoupon_Eligible(t *testing.T) {
+	t.Parallel()
+	assert.True(t, Default("ANY").Eligible("1", "Waffle"))
+
+	c := Coupon{ProductIDs: []string{"1"}, Categories: []string{"Cake"}}
+	assert.True(t, c.Eligible("1", "Waffle"))
+	assert.True(t, c.Eligible("7", "Cake"))
+	assert.False(t, c.Eligible("2", "Waffle"))
 }
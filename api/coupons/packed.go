@@ -0,0 +1,123 @@
+package coupons
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// packedMagic identifies the [Packed] format, the final byte is the format version.
+const packedMagic = "KCP\x01"
+
+// packedHeaderSize is the size of the [Packed] header: magic, code width and code count.
+const packedHeaderSize = len(packedMagic) + 1 + 4
+
+var _ Store = Packed{}
+
+// Packed is a [Store] of coupon codes held in a single sorted array of fixed width entries, codes
+// shorter than the width are padded with zero bytes. Lookups binary search the array so it can
+// be served straight from embedded data without building anything at startup. Every code is given
+// the [Default] rule.
+//
+// Build the data with [Pack].
+type Packed struct {
+	width int
+	codes string
+}
+
+// NewPacked creates a [Packed] store from data created by [Pack]. data is used as is, not copied.
+func NewPacked(data string) (Packed, error) {
+	if len(data) < packedHeaderSize || data[:len(packedMagic)] != packedMagic {
+		return Packed{}, errors.New("packed coupons: unrecognised format")
+	}
+	width := int(data[len(packedMagic)])
+	n := int(binary.BigEndian.Uint32([]byte(data[len(packedMagic)+1 : packedHeaderSize])))
+	codes := data[packedHeaderSize:]
+	if width == 0 || len(codes) != n*width {
+		return Packed{}, fmt.Errorf("packed coupons: expected %d codes of %d bytes but have %d bytes", n, width, len(codes))
+	}
+	return Packed{width: width, codes: codes}, nil
+}
+
+// Pack encodes codes for [NewPacked]. Duplicate and empty codes are dropped.
+func Pack(codes []string) ([]byte, error) {
+	codes = slices.DeleteFunc(slices.Clone(codes), func(c string) bool { return c == "" })
+	slices.Sort(codes)
+	codes = slices.Compact(codes)
+	width := 0
+	for _, v := range codes {
+		if strings.IndexByte(v, 0) >= 0 {
+			return nil, fmt.Errorf("packed coupons: code %q contains a zero byte", v)
+		}
+		width = max(width, len(v))
+	}
+	if width > 255 {
+		return nil, fmt.Errorf("packed coupons: codes cannot be longer than 255 bytes")
+	}
+	b := make([]byte, 0, packedHeaderSize+len(codes)*width)
+	b = append(b, packedMagic...)
+	b = append(b, byte(max(width, 1)))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(codes)))
+	for _, v := range codes {
+		b = append(b, v...)
+		b = append(b, make([]byte, max(width, 1)-len(v))...)
+	}
+	return b, nil
+}
+
+// Lookup implements [Store.Lookup].
+func (p Packed) Lookup(ctx context.Context, code string) (Coupon, error) {
+	if err := ctx.Err(); err != nil {
+		return Coupon{}, err
+	}
+	if code == "" || len(code) > p.width {
+		return Coupon{}, notFound(code)
+	}
+	n := p.Len()
+	i := sort.Search(n, func(i int) bool { return p.entry(i) >= code })
+	if i == n || !p.is(i, code) {
+		return Coupon{}, notFound(code)
+	}
+	return Default(code), nil
+}
+
+// Len returns the number of codes in p.
+func (p Packed) Len() int {
+	if p.width == 0 {
+		return 0
+	}
+	return len(p.codes) / p.width
+}
+
+// All iterates over every code in p in sorted order.
+func (p Packed) All() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for i := range p.Len() {
+			if !yield(p.code(i)) {
+				return
+			}
+		}
+	}
+}
+
+// entry returns the i'th entry including padding. Padding sorts before every other byte so
+// comparing a code with an entry orders the same as comparing it with the unpadded code.
+func (p Packed) entry(i int) string {
+	return p.codes[i*p.width : (i+1)*p.width]
+}
+
+// is reports whether the i'th code is code.
+func (p Packed) is(i int, code string) bool {
+	e := p.entry(i)
+	return e[:len(code)] == code && (len(code) == p.width || e[len(code)] == 0)
+}
+
+// code returns the i'th code without padding.
+func (p Packed) code(i int) string {
+	return strings.TrimRight(p.entry(i), "\x00")
+}
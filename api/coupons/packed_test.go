@@ -0,0 +1,140 @@
+package coupons
+
+import (
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacked_Lookup(t *testing.T) {
+	t.Parallel()
+	b, err := Pack([]string{"OVER9000", "HAPPYHRS", "FIFTYOFF", "HAPPYHOURS", "OVER9000", ""})
+	require.NoError(t, err)
+	p, err := NewPacked(string(b))
+	require.NoError(t, err)
+	assert.Equal(t, 4, p.Len(), "duplicates and empty codes dropped")
+	assert.Equal(t, []string{"FIFTYOFF", "HAPPYHOURS", "HAPPYHRS", "OVER9000"}, slices.Collect(p.All()))
+
+	for _, code := range []string{"OVER9000", "HAPPYHRS", "FIFTYOFF", "HAPPYHOURS"} {
+		c, err := p.Lookup(t.Context(), code)
+		require.NoError(t, err, code)
+		assert.Equal(t, Default(code), c)
+	}
+	for _, code := range []string{"", "OVER900", "OVER90000", "HAPPYHOURSX", "AAAAAAAA", "ZZZZZZZZ"} {
+		_, err := p.Lookup(t.Context(), code)
+		ae, ok := err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, apperr.CodeNotFound, ae.Code, code)
+	}
+}
+
+func TestPacked_DB(t *testing.T) {
+	t.Parallel()
+	p, err := NewPacked(DB)
+	require.NoError(t, err)
+	m, err := NewMem(strings.NewReader(dbText(t)))
+	require.NoError(t, err)
+	require.Equal(t, len(m.(Mem)), p.Len())
+	for code := range m.(Mem) {
+		_, err := p.Lookup(t.Context(), code)
+		assert.NoError(t, err, code)
+	}
+	c, err := p.Lookup(t.Context(), "OVER9000")
+	require.NoError(t, err)
+	assert.Equal(t, Default("OVER9000"), c)
+}
+
+func TestNewPacked(t *testing.T) {
+	t.Parallel()
+	_, err := NewPacked("PUG1Z7VC\n90KEVW2W\n")
+	assert.EqualError(t, err, "packed coupons: unrecognised format")
+	b, err := Pack([]string{"OVER9000"})
+	require.NoError(t, err)
+	_, err = NewPacked(string(b[:len(b)-1]))
+	assert.EqualError(t, err, "packed coupons: expected 1 codes of 8 bytes but have 7 bytes")
+
+	b, err = Pack(nil)
+	require.NoError(t, err)
+	p, err := NewPacked(string(b))
+	require.NoError(t, err)
+	_, err = p.Lookup(t.Context(), "OVER9000")
+	assert.Error(t, err)
+}
+
+// BenchmarkStartup measures building each store from its embedded format.
+func BenchmarkStartup(b *testing.B) {
+	text := dbText(b)
+	b.Run("mem", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := NewMem(strings.NewReader(text)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("packed", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := NewPacked(DB); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkMemory reports the heap retained by each store.
+func BenchmarkMemory(b *testing.B) {
+	text := dbText(b)
+	heap := func() uint64 {
+		runtime.GC()
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		return ms.HeapAlloc
+	}
+	for name, open := range map[string]func() (Store, error){
+		"mem":    func() (Store, error) { return NewMem(strings.NewReader(text)) },
+		"packed": func() (Store, error) { return NewPacked(DB) },
+	} {
+		b.Run(name, func(b *testing.B) {
+			var retained uint64
+			for b.Loop() {
+				before := heap()
+				s, err := open()
+				if err != nil {
+					b.Fatal(err)
+				}
+				retained = max(heap(), before) - before
+				runtime.KeepAlive(s)
+			}
+			b.ReportMetric(float64(retained), "retained-B")
+		})
+	}
+}
+
+func BenchmarkLookup(b *testing.B) {
+	p, err := NewPacked(DB)
+	if err != nil {
+		b.Fatal(err)
+	}
+	m, err := NewMem(strings.NewReader(dbText(b)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	codes := slices.Collect(p.All())
+	for name, s := range map[string]Store{"mem": m, "packed": p} {
+		b.Run(name, func(b *testing.B) {
+			i := 0
+			for b.Loop() {
+				if _, err := s.Lookup(b.Context(), codes[i%len(codes)]); err != nil {
+					b.Fatal(err)
+				}
+				i += 7919
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package coupons
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/txn"
+)
+
+// Use identifies who used a coupon and for what.
+type Use struct {
+	OrderID string
+	// APIKey identifies the principal that placed the order.
+	APIKey string
+	// CustomerID identifies the customer the order was placed for, empty when unknown.
+	CustomerID string
+}
+
+// Redemptions tracks the uses of coupons that can only be used a limited number of times.
+type Redemptions interface {
+	// Redeem records u as a use of c. Checking the [Limits] of c and recording the use happen
+	// atomically so concurrent orders can't both take the last use. Returns an
+	// [apperr.CodeConstraint] error if u would exceed any of the limits. Coupons that aren't
+	// [Coupon.Limited] are never tracked. The use is released if ctx belongs to a [txn.Tx] that
+	// is rolled back.
+	Redeem(ctx context.Context, c Coupon, u Use) error
+	// Release gives back the use of the coupon with code made by the order with orderID.
+	// Releasing a use that was never redeemed does nothing. The use is taken again if ctx belongs
+	// to a [txn.Tx] that is rolled back.
+	Release(ctx context.Context, code, orderID string) error
+}
+
+var _ Redemptions = &MemRedemptions{}
+
+// NewMemRedemptions creates an empty [MemRedemptions].
+func NewMemRedemptions() *MemRedemptions {
+	return &MemRedemptions{data: map[string]map[string]Use{}}
+}
+
+// MemRedemptions is a [Redemptions] that records uses in memory.
+type MemRedemptions struct {
+	mu sync.Mutex
+	// data is the uses of each coupon code keyed by order ID.
+	data map[string]map[string]Use
+}
+
+// Redeem implements [Redemptions.Redeem].
+func (m *MemRedemptions) Redeem(ctx context.Context, c Coupon, u Use) error {
+	if !c.Limited() {
+		return nil
+	}
+	if c.MaxPerCustomer > 0 && u.CustomerID == "" {
+		return apperr.NewError(apperr.CodeConstraint, fmt.Errorf("coupon %s can only be used by orders with a customerId", c.Code))
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	used := m.data[c.Code]
+	if _, has := used[u.OrderID]; has {
+		return nil
+	}
+	byKey, byCustomer := 0, 0
+	for _, v := range used {
+		if v.APIKey == u.APIKey {
+			byKey++
+		}
+		if u.CustomerID != "" && v.CustomerID == u.CustomerID {
+			byCustomer++
+		}
+	}
+	ve := []error{}
+	if c.MaxRedemptions > 0 && len(used) >= c.MaxRedemptions {
+		ve = append(ve, fmt.Errorf("coupon %s has no uses left", c.Code))
+	}
+	if c.MaxPerAPIKey > 0 && byKey >= c.MaxPerAPIKey {
+		ve = append(ve, fmt.Errorf("coupon %s has no uses left for this API key", c.Code))
+	}
+	if c.MaxPerCustomer > 0 && byCustomer >= c.MaxPerCustomer {
+		ve = append(ve, fmt.Errorf("coupon %s has no uses left for customer %s", c.Code, u.CustomerID))
+	}
+	if len(ve) > 0 {
+		return apperr.NewError(apperr.CodeConstraint, errors.Join(ve...))
+	}
+	if used == nil {
+		used = map[string]Use{}
+		m.data[c.Code] = used
+	}
+	used[u.OrderID] = u
+	txn.OnRollback(ctx, func(ctx context.Context) error { return m.Release(ctx, c.Code, u.OrderID) })
+	return nil
+}
+
+// Release implements [Redemptions.Release].
+func (m *MemRedemptions) Release(ctx context.Context, code, orderID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, has := m.data[code][orderID]
+	if !has {
+		return nil
+	}
+	delete(m.data[code], orderID)
+	txn.OnRollback(ctx, func(context.Context) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.data[code][orderID] = u
+		return nil
+	})
+	return nil
+}
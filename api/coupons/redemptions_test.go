@@ -0,0 +1,141 @@
+package coupons
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/txn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemRedemptions(t *testing.T) {
+	t.Parallel()
+
+	once := Coupon{Code: "ONCE", Kind: KindPercentage, Percent: 10, Limits: Limits{MaxRedemptions: 1}}
+
+	// assertExhausted checks err is the constraint error returned when a coupon can't be used.
+	assertExhausted := func(t *testing.T, err error, msg string) {
+		t.Helper()
+		ae, ok := err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, apperr.CodeConstraint, ae.Code)
+		assert.ErrorContains(t, err, msg)
+	}
+
+	t.Run("limited coupon", func(t *testing.T) {
+		t.Parallel()
+		m := NewMemRedemptions()
+		require.NoError(t, m.Redeem(t.Context(), once, Use{OrderID: "a"}))
+		// redeeming again for the same order doesn't take another use
+		require.NoError(t, m.Redeem(t.Context(), once, Use{OrderID: "a"}))
+
+		err := m.Redeem(t.Context(), once, Use{OrderID: "b"})
+		assertExhausted(t, err, "coupon ONCE has no uses left")
+	})
+
+	t.Run("per api key", func(t *testing.T) {
+		t.Parallel()
+		m := NewMemRedemptions()
+		c := Coupon{Code: "TWICE", Kind: KindPercentage, Percent: 10, Limits: Limits{MaxPerAPIKey: 2}}
+		require.NoError(t, m.Redeem(t.Context(), c, Use{OrderID: "a", APIKey: "k1"}))
+		require.NoError(t, m.Redeem(t.Context(), c, Use{OrderID: "b", APIKey: "k1"}))
+		require.NoError(t, m.Redeem(t.Context(), c, Use{OrderID: "c", APIKey: "k2"}))
+
+		err := m.Redeem(t.Context(), c, Use{OrderID: "d", APIKey: "k1"})
+		assertExhausted(t, err, "coupon TWICE has no uses left for this API key")
+	})
+
+	t.Run("per customer", func(t *testing.T) {
+		t.Parallel()
+		m := NewMemRedemptions()
+		c := Coupon{Code: "WELCOME", Kind: KindPercentage, Percent: 10, Limits: Limits{MaxPerCustomer: 1}}
+		require.NoError(t, m.Redeem(t.Context(), c, Use{OrderID: "a", APIKey: "k1", CustomerID: "alice"}))
+		require.NoError(t, m.Redeem(t.Context(), c, Use{OrderID: "b", APIKey: "k1", CustomerID: "bob"}))
+
+		err := m.Redeem(t.Context(), c, Use{OrderID: "c", APIKey: "k2", CustomerID: "alice"})
+		assertExhausted(t, err, "coupon WELCOME has no uses left for customer alice")
+
+		err = m.Redeem(t.Context(), c, Use{OrderID: "d", APIKey: "k1"})
+		assertExhausted(t, err, "coupon WELCOME can only be used by orders with a customerId")
+	})
+
+	t.Run("release gives use back", func(t *testing.T) {
+		t.Parallel()
+		m := NewMemRedemptions()
+		require.NoError(t, m.Redeem(t.Context(), once, Use{OrderID: "a"}))
+		require.NoError(t, m.Release(t.Context(), "ONCE", "a"))
+		require.NoError(t, m.Redeem(t.Context(), once, Use{OrderID: "b"}))
+	})
+
+	t.Run("rollback gives use back", func(t *testing.T) {
+		t.Parallel()
+		m := NewMemRedemptions()
+		ctx, tx := txn.Begin(t.Context())
+		require.NoError(t, m.Redeem(ctx, once, Use{OrderID: "a"}))
+		require.NoError(t, tx.Rollback(ctx))
+		require.NoError(t, m.Redeem(t.Context(), once, Use{OrderID: "b"}))
+	})
+
+	t.Run("rollback takes released use back", func(t *testing.T) {
+		t.Parallel()
+		m := NewMemRedemptions()
+		require.NoError(t, m.Redeem(t.Context(), once, Use{OrderID: "a"}))
+		ctx, tx := txn.Begin(t.Context())
+		require.NoError(t, m.Release(ctx, "ONCE", "a"))
+		require.NoError(t, tx.Rollback(ctx))
+		assertExhausted(t, m.Redeem(t.Context(), once, Use{OrderID: "b"}), "coupon ONCE has no uses left")
+	})
+
+	t.Run("release unknown use", func(t *testing.T) {
+		t.Parallel()
+		assert.NoError(t, NewMemRedemptions().Release(t.Context(), "ONCE", "a"))
+	})
+
+	t.Run("unlimited coupon", func(t *testing.T) {
+		t.Parallel()
+		m := NewMemRedemptions()
+		for _, id := range []string{"a", "b", "c"} {
+			require.NoError(t, m.Redeem(t.Context(), Default("OVER9000"), Use{OrderID: id}))
+		}
+		assert.Empty(t, m.data)
+	})
+
+	t.Run("concurrent redemptions take the last use once", func(t *testing.T) {
+		t.Parallel()
+		m := NewMemRedemptions()
+		c := Coupon{Code: "TEN", Kind: KindPercentage, Percent: 10, Limits: Limits{MaxRedemptions: 10}}
+		var redeemed atomic.Int32
+		wg := sync.WaitGroup{}
+		for i := range 100 {
+			wg.Go(func() {
+				if m.Redeem(t.Context(), c, Use{OrderID: string(rune('a' + i))}) == nil {
+					redeemed.Add(1)
+				}
+			})
+		}
+		wg.Wait()
+		assert.Equal(t, int32(10), redeemed.Load())
+	})
+}
+
+func TestWithLimits(t *testing.T) {
+	t.Parallel()
+	limits := Limits{MaxPerCustomer: 1}
+	r, err := NewRules(Coupon{Code: "ONCE", Kind: KindPercentage, Percent: 10, Limits: Limits{MaxRedemptions: 1}}, Default("ANY"))
+	require.NoError(t, err)
+	s := WithLimits(r, limits)
+
+	c, err := s.Lookup(t.Context(), "ANY")
+	require.NoError(t, err)
+	assert.Equal(t, limits, c.Limits, "coupons without limits get the defaults")
+
+	c, err = s.Lookup(t.Context(), "ONCE")
+	require.NoError(t, err)
+	assert.Equal(t, Limits{MaxRedemptions: 1}, c.Limits, "coupons keep their own limits")
+
+	_, err = s.Lookup(t.Context(), "NONE")
+	assert.ErrorContains(t, err, "coupon NONE not found")
+}
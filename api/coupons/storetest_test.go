@@ -0,0 +1,56 @@
+package coupons_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/coupons"
+	"github.com/matgreaves/kart-challenge/api/storetest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreConformance(t *testing.T) {
+	t.Parallel()
+
+	newMem := func(t *testing.T, codes []string) coupons.Store {
+		m, err := coupons.NewMem(strings.NewReader(strings.Join(codes, "\n")))
+		require.NoError(t, err)
+		return m
+	}
+
+	t.Run("Mem", func(t *testing.T) {
+		t.Parallel()
+		storetest.Coupons(t, newMem)
+	})
+
+	t.Run("Rules", func(t *testing.T) {
+		t.Parallel()
+		storetest.Coupons(t, func(t *testing.T, codes []string) coupons.Store {
+			cs := []coupons.Coupon{}
+			for _, v := range codes {
+				cs = append(cs, coupons.Default(v))
+			}
+			r, err := coupons.NewRules(cs...)
+			require.NoError(t, err)
+			return r
+		})
+	})
+
+	t.Run("Packed", func(t *testing.T) {
+		t.Parallel()
+		storetest.Coupons(t, func(t *testing.T, codes []string) coupons.Store {
+			b, err := coupons.Pack(codes)
+			require.NoError(t, err)
+			p, err := coupons.NewPacked(string(b))
+			require.NoError(t, err)
+			return p
+		})
+	})
+
+	t.Run("WithLimits", func(t *testing.T) {
+		t.Parallel()
+		storetest.Coupons(t, func(t *testing.T, codes []string) coupons.Store {
+			return coupons.WithLimits(newMem(t, codes), coupons.Limits{})
+		})
+	})
+}
@@ -0,0 +1,57 @@
+// package fold reduces text to a form that ignores differences people don't notice when reading
+// it, such as case and accents.
+package fold
+
+import (
+	"strings"
+	"unicode"
+)
+
+// unaccent replaces lower case accented latin letters with the letters they're based on.
+var unaccent = func() *strings.Replacer {
+	oldnew := []string{}
+	for base, accented := range map[string]string{
+		"a": "àáâãäåāăą", "c": "çćĉċč", "d": "ďđ", "e": "èéêëēĕėęě", "g": "ĝğġģ", "h": "ĥħ",
+		"i": "ìíîïĩīĭįı", "j": "ĵ", "k": "ķ", "l": "ĺļľŀł", "n": "ñńņňŉ", "o": "òóôõöøōŏő",
+		"r": "ŕŗř", "s": "śŝşš", "t": "ţťŧ", "u": "ùúûüũūŭůűų", "w": "ŵ", "y": "ýÿŷ", "z": "źżž",
+		"ae": "æ", "oe": "œ", "ss": "ß",
+	} {
+		for _, r := range accented {
+			oldnew = append(oldnew, string(r), base)
+		}
+	}
+	return strings.NewReplacer(oldnew...)
+}()
+
+// String returns s in a form that compares equal to other forms of s that only differ by case or
+// accents, "Crème Brûlée" folds to "creme brulee".
+func String(s string) string {
+	// drop combining marks so decomposed accents fold the same as precomposed ones
+	s = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, s)
+	return unaccent.Replace(strings.ToLower(s))
+}
+
+// Slug returns s folded, see [String], with every run of characters other than letters and digits
+// replaced by a single "-" and none at either end, "Crème Brûlée" is "creme-brulee". A slug is its
+// own slug and can be used as a URL path segment.
+func Slug(s string) string {
+	b := strings.Builder{}
+	dash := false
+	for _, r := range String(s) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			dash = b.Len() > 0
+			continue
+		}
+		if dash {
+			b.WriteByte('-')
+			dash = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,38 @@
+package fold
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestString(t *testing.T) {
+	t.Parallel()
+	for in, want := range map[string]string{
+		"Crème Brûlée":    "creme brulee",
+		"CRÈME BRÛLÉE":    "creme brulee",
+		"Cre\u0300me":     "creme",
+		"Smørrebrød":      "smorrebrod",
+		"Straße":          "strasse",
+		"Waffle":          "waffle",
+		"Œufs à la neige": "oeufs a la neige",
+		"Pão de queijo 🧀": "pao de queijo 🧀",
+	} {
+		assert.Equal(t, want, String(in), in)
+	}
+}
+
+func TestSlug(t *testing.T) {
+	t.Parallel()
+	for in, want := range map[string]string{
+		"Crème Brûlée":      "creme-brulee",
+		"creme-brulee":      "creme-brulee",
+		"Panna Cotta":       "panna-cotta",
+		"  Pies & Tarts!  ": "pies-tarts",
+		"Waffle":            "waffle",
+		"7-Layer Cake":      "7-layer-cake",
+		"🧀":                 "",
+	} {
+		assert.Equal(t, want, Slug(in), in)
+	}
+}
@@ -0,0 +1,153 @@
+// package idempotency lets clients safely retry requests by replaying the original response to
+// requests that repeat an idempotency key.
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRetention is how long a completed response is kept for replay.
+	DefaultRetention = 24 * time.Hour
+	// sweepInterval is the most often [Mem] will walk every key looking for expired ones.
+	sweepInterval = time.Minute
+)
+
+// ErrKeyReused is returned when a key is presented with a different request to the one it was
+// first used with.
+var ErrKeyReused = errors.New("idempotency key has already been used with a different request")
+
+// Response is a recorded response replayed to retried requests.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Store is the interface for tracking idempotency keys.
+type Store interface {
+	// Begin claims key for a request identified by fingerprint.
+	//
+	// If the key is new it is claimed by the caller and both return values are nil, the caller
+	// must then call either Complete or Abandon. If the key has already completed the recorded
+	// response is returned. If another request currently holds the key Begin waits for it to
+	// finish. If the key was used with a different fingerprint [ErrKeyReused] is returned.
+	Begin(ctx context.Context, key, fingerprint string) (*Response, error)
+	// Complete records res against a key claimed with Begin so it can be replayed.
+	Complete(ctx context.Context, key string, res Response) error
+	// Abandon releases a key claimed with Begin without recording a response, letting
+	// the request be retried.
+	Abandon(ctx context.Context, key string) error
+}
+
+var _ Store = &Mem{}
+
+// NewMem creates a [Mem] store that keeps completed responses for retention.
+func NewMem(retention time.Duration) *Mem {
+	return &Mem{
+		retention: retention,
+		now:       time.Now,
+		data:      map[string]*entry{},
+	}
+}
+
+// Mem is a [Store] that keeps keys in memory, expired keys are swept as new keys are claimed.
+type Mem struct {
+	retention time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	data      map[string]*entry
+	lastSweep time.Time
+}
+
+type entry struct {
+	fingerprint string
+	// done is closed once the request holding the key completes or abandons it.
+	done    chan struct{}
+	res     *Response
+	expires time.Time
+}
+
+// Begin implements [Store.Begin].
+func (m *Mem) Begin(ctx context.Context, key, fingerprint string) (*Response, error) {
+	for {
+		m.mu.Lock()
+		now := m.now()
+		m.sweep(now)
+		e, has := m.data[key]
+		if has && e.expired(now) {
+			delete(m.data, key)
+			has = false
+		}
+		if !has {
+			m.data[key] = &entry{fingerprint: fingerprint, done: make(chan struct{})}
+			m.mu.Unlock()
+			return nil, nil
+		}
+		m.mu.Unlock()
+
+		if e.fingerprint != fingerprint {
+			return nil, ErrKeyReused
+		}
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		// a completed entry is never modified again, an abandoned one has been removed so
+		// loop around and try to claim the key ourselves.
+		if e.res != nil {
+			return e.res, nil
+		}
+	}
+}
+
+// Complete implements [Store.Complete].
+func (m *Mem) Complete(_ context.Context, key string, res Response) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, has := m.data[key]
+	if !has || e.res != nil {
+		return errors.New("idempotency key not claimed")
+	}
+	e.res = &res
+	e.expires = m.now().Add(m.retention)
+	close(e.done)
+	return nil
+}
+
+// Abandon implements [Store.Abandon].
+func (m *Mem) Abandon(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, has := m.data[key]
+	if !has || e.res != nil {
+		return errors.New("idempotency key not claimed")
+	}
+	delete(m.data, key)
+	close(e.done)
+	return nil
+}
+
+// sweep removes expired keys, it only walks every key once per sweepInterval so is cheap to call
+// often. Must be called with m.mu held.
+func (m *Mem) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for k, e := range m.data {
+		if e.expired(now) {
+			delete(m.data, k)
+		}
+	}
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return e.res != nil && !now.Before(e.expires)
+}
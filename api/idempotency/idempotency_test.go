@@ -0,0 +1,112 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMem(t *testing.T) {
+	t.Parallel()
+
+	want := Response{StatusCode: 200, Body: []byte(`{"id":"1"}`)}
+
+	t.Run("replays completed response", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem(time.Hour)
+		res, err := m.Begin(t.Context(), "k", "a")
+		require.NoError(t, err)
+		require.Nil(t, res)
+		require.NoError(t, m.Complete(t.Context(), "k", want))
+
+		res, err = m.Begin(t.Context(), "k", "a")
+		require.NoError(t, err)
+		assert.Equal(t, &want, res)
+	})
+
+	t.Run("key reused with different fingerprint", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem(time.Hour)
+		_, err := m.Begin(t.Context(), "k", "a")
+		require.NoError(t, err)
+
+		_, err = m.Begin(t.Context(), "k", "b")
+		assert.ErrorIs(t, err, ErrKeyReused)
+
+		require.NoError(t, m.Complete(t.Context(), "k", want))
+		_, err = m.Begin(t.Context(), "k", "b")
+		assert.ErrorIs(t, err, ErrKeyReused)
+	})
+
+	t.Run("abandoned key can be claimed again", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem(time.Hour)
+		_, err := m.Begin(t.Context(), "k", "a")
+		require.NoError(t, err)
+		require.NoError(t, m.Abandon(t.Context(), "k"))
+
+		res, err := m.Begin(t.Context(), "k", "a")
+		require.NoError(t, err)
+		assert.Nil(t, res)
+	})
+
+	t.Run("concurrent requests wait for the first", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem(time.Hour)
+		_, err := m.Begin(t.Context(), "k", "a")
+		require.NoError(t, err)
+
+		wg := sync.WaitGroup{}
+		results := make([]*Response, 10)
+		for i := range results {
+			wg.Go(func() {
+				res, err := m.Begin(t.Context(), "k", "a")
+				assert.NoError(t, err)
+				results[i] = res
+			})
+		}
+		require.NoError(t, m.Complete(t.Context(), "k", want))
+		wg.Wait()
+		for _, res := range results {
+			assert.Equal(t, &want, res)
+		}
+	})
+
+	t.Run("waiting respects context", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem(time.Hour)
+		_, err := m.Begin(t.Context(), "k", "a")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+		_, err = m.Begin(ctx, "k", "a")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("expired keys are forgotten", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem(time.Hour)
+		now := time.Now()
+		m.now = func() time.Time { return now }
+
+		_, err := m.Begin(t.Context(), "k", "a")
+		require.NoError(t, err)
+		require.NoError(t, m.Complete(t.Context(), "k", want))
+		_, err = m.Begin(t.Context(), "other", "a")
+		require.NoError(t, err)
+		require.NoError(t, m.Complete(t.Context(), "other", want))
+
+		now = now.Add(time.Hour)
+		res, err := m.Begin(t.Context(), "k", "b")
+		require.NoError(t, err)
+		assert.Nil(t, res)
+		m.mu.Lock()
+		assert.NotContains(t, m.data, "other")
+		m.mu.Unlock()
+	})
+}
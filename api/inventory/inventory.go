@@ -0,0 +1,182 @@
+// package inventory tracks how much of each product is in stock and reserves stock for orders.
+//
+// Only products that have had a stock level set are tracked, every other product is treated as
+// always in stock.
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/txn"
+)
+
+// Line is a quantity of a single product to reserve.
+type Line struct {
+	ProductID string
+	Quantity  int
+}
+
+// Level is the stock of a single product.
+type Level struct {
+	ProductID string `json:"productId"`
+	// Available is the number of units that can still be ordered.
+	Available int `json:"available"`
+	// LowStock logs a warning once Available falls to or below it, zero to never warn.
+	LowStock int `json:"lowStock,omitempty"`
+}
+
+// Validate checks whether l is well formed.
+func (l Level) Validate() error {
+	ve := []error{}
+	if l.ProductID == "" {
+		ve = append(ve, errors.New("productId is required"))
+	}
+	if l.Available < 0 {
+		ve = append(ve, errors.New("available cannot be less than zero"))
+	}
+	if l.LowStock < 0 {
+		ve = append(ve, errors.New("lowStock cannot be less than zero"))
+	}
+	if len(ve) > 0 {
+		return errors.Join(ve...)
+	}
+	return nil
+}
+
+// Store is the interface for interacting with stock levels.
+type Store interface {
+	// Reserve takes stock for every line on behalf of the order with orderID. Either every line is
+	// reserved or none are. Returns an [apperr.CodeConstraint] error describing each line, by its
+	// index in lines, that doesn't have enough stock. Reserving again for the same order does
+	// nothing. The stock is released if ctx belongs to a [txn.Tx] that is rolled back.
+	Reserve(ctx context.Context, orderID string, lines []Line) error
+	// Release returns the stock reserved by the order with orderID. Releasing an order that has
+	// no reservation does nothing. The stock is reserved again if ctx belongs to a [txn.Tx] that
+	// is rolled back.
+	Release(ctx context.Context, orderID string) error
+	// Get returns the stock level of productID or an [apperr.CodeNotFound] error if it isn't
+	// tracked.
+	Get(ctx context.Context, productID string) (Level, error)
+	// Set replaces the stock level of l.ProductID, starting to track it if it wasn't already.
+	Set(ctx context.Context, l Level) (Level, error)
+}
+
+var _ Store = &Mem{}
+
+// NewMem creates an empty [Mem] logging low stock warnings to logger.
+func NewMem(logger *slog.Logger) *Mem {
+	return &Mem{
+		logger:       logger,
+		levels:       map[string]Level{},
+		reservations: map[string][]Line{},
+	}
+}
+
+// Mem is a [Store] that keeps stock levels in memory.
+type Mem struct {
+	logger *slog.Logger
+
+	mu           sync.Mutex
+	levels       map[string]Level
+	reservations map[string][]Line
+}
+
+// Reserve implements [Store.Reserve].
+func (m *Mem) Reserve(ctx context.Context, orderID string, lines []Line) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, has := m.reservations[orderID]; has {
+		return nil
+	}
+	// the same product may appear on more than one line so total them before checking
+	wanted := map[string]int{}
+	ve := []error{}
+	for i, v := range lines {
+		l, tracked := m.levels[v.ProductID]
+		if !tracked {
+			continue
+		}
+		wanted[v.ProductID] += v.Quantity
+		if wanted[v.ProductID] > l.Available {
+			ve = append(ve, fmt.Errorf("item[%d] only %d of product %s in stock", i, l.Available, v.ProductID))
+		}
+	}
+	if len(ve) > 0 {
+		return apperr.NewError(apperr.CodeConstraint, errors.Join(ve...))
+	}
+	reserved := []Line{}
+	for id, q := range wanted {
+		l := m.levels[id]
+		l.Available -= q
+		m.levels[id] = l
+		reserved = append(reserved, Line{ProductID: id, Quantity: q})
+		if l.LowStock > 0 && l.Available <= l.LowStock && l.Available+q > l.LowStock {
+			m.logger.WarnContext(ctx, "product stock low",
+				slog.String("productId", id),
+				slog.Int("available", l.Available),
+				slog.Int("lowStock", l.LowStock),
+			)
+		}
+	}
+	if len(reserved) > 0 {
+		m.reservations[orderID] = reserved
+		txn.OnRollback(ctx, func(ctx context.Context) error { return m.Release(ctx, orderID) })
+	}
+	return nil
+}
+
+// Release implements [Store.Release].
+func (m *Mem) Release(ctx context.Context, orderID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	reserved, has := m.reservations[orderID]
+	if !has {
+		return nil
+	}
+	for _, v := range reserved {
+		l := m.levels[v.ProductID]
+		l.Available += v.Quantity
+		m.levels[v.ProductID] = l
+	}
+	delete(m.reservations, orderID)
+	// the stock may have been taken since so undoing can leave it below zero until it's next set
+	txn.OnRollback(ctx, func(context.Context) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for _, v := range reserved {
+			l := m.levels[v.ProductID]
+			l.Available -= v.Quantity
+			m.levels[v.ProductID] = l
+		}
+		m.reservations[orderID] = reserved
+		return nil
+	})
+	return nil
+}
+
+// Get implements [Store.Get].
+func (m *Mem) Get(_ context.Context, productID string) (Level, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, has := m.levels[productID]
+	if !has {
+		return Level{}, apperr.NewError(apperr.CodeNotFound, fmt.Errorf("stock for product %s not found", productID))
+	}
+	return l, nil
+}
+
+// Set implements [Store.Set].
+func (m *Mem) Set(_ context.Context, l Level) (Level, error) {
+	if err := l.Validate(); err != nil {
+		return Level{}, apperr.NewError(apperr.CodeConstraint, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.levels[l.ProductID] = l
+	return l, nil
+}
@@ -0,0 +1,141 @@
+package inventory
+
+import (
+	"bytes"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/txn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMem(t *testing.T) {
+	t.Parallel()
+
+	// available returns the stock available of productID failing the test if it isn't tracked.
+	available := func(t *testing.T, m *Mem, productID string) int {
+		t.Helper()
+		l, err := m.Get(t.Context(), productID)
+		require.NoError(t, err)
+		return l.Available
+	}
+
+	t.Run("reserve and release", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem(slog.New(slog.DiscardHandler))
+		_, err := m.Set(t.Context(), Level{ProductID: "1", Available: 5})
+		require.NoError(t, err)
+
+		require.NoError(t, m.Reserve(t.Context(), "a", []Line{{ProductID: "1", Quantity: 2}, {ProductID: "2", Quantity: 100}}))
+		assert.Equal(t, 3, available(t, m, "1"))
+		require.NoError(t, m.Reserve(t.Context(), "a", []Line{{ProductID: "1", Quantity: 2}}))
+		assert.Equal(t, 3, available(t, m, "1"), "reserving twice for an order only takes stock once")
+
+		require.NoError(t, m.Release(t.Context(), "a"))
+		assert.Equal(t, 5, available(t, m, "1"))
+		require.NoError(t, m.Release(t.Context(), "a"))
+		assert.Equal(t, 5, available(t, m, "1"), "releasing twice only gives stock back once")
+	})
+
+	t.Run("rollback releases", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem(slog.New(slog.DiscardHandler))
+		_, err := m.Set(t.Context(), Level{ProductID: "1", Available: 5})
+		require.NoError(t, err)
+
+		ctx, tx := txn.Begin(t.Context())
+		require.NoError(t, m.Reserve(ctx, "a", []Line{{ProductID: "1", Quantity: 2}}))
+		assert.Equal(t, 3, available(t, m, "1"))
+		require.NoError(t, tx.Rollback(ctx))
+		assert.Equal(t, 5, available(t, m, "1"))
+
+		ctx, tx = txn.Begin(t.Context())
+		require.NoError(t, m.Reserve(ctx, "b", []Line{{ProductID: "1", Quantity: 2}}))
+		tx.Commit()
+		assert.Equal(t, 3, available(t, m, "1"), "committed reservations are kept")
+
+		ctx, tx = txn.Begin(t.Context())
+		require.NoError(t, m.Release(ctx, "b"))
+		assert.Equal(t, 5, available(t, m, "1"))
+		require.NoError(t, tx.Rollback(ctx))
+		assert.Equal(t, 3, available(t, m, "1"), "rolled back release reserves again")
+		require.NoError(t, m.Release(t.Context(), "b"))
+		assert.Equal(t, 5, available(t, m, "1"))
+	})
+
+	t.Run("not enough stock reserves nothing", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem(slog.New(slog.DiscardHandler))
+		_, err := m.Set(t.Context(), Level{ProductID: "1", Available: 5})
+		require.NoError(t, err)
+		_, err = m.Set(t.Context(), Level{ProductID: "2", Available: 1})
+		require.NoError(t, err)
+
+		err = m.Reserve(t.Context(), "a", []Line{{ProductID: "1", Quantity: 5}, {ProductID: "2", Quantity: 2}, {ProductID: "3", Quantity: 100}})
+		ae, ok := err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, apperr.CodeConstraint, ae.Code)
+		assert.EqualError(t, ae.Cause, "item[1] only 1 of product 2 in stock")
+		assert.Equal(t, 5, available(t, m, "1"))
+		assert.Equal(t, 1, available(t, m, "2"))
+	})
+
+	t.Run("untracked product", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem(slog.New(slog.DiscardHandler))
+		require.NoError(t, m.Reserve(t.Context(), "a", []Line{{ProductID: "1", Quantity: 1000}}))
+		_, err := m.Get(t.Context(), "1")
+		ae, ok := err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, apperr.CodeNotFound, ae.Code)
+	})
+
+	t.Run("low stock warning", func(t *testing.T) {
+		t.Parallel()
+		logs := &bytes.Buffer{}
+		m := NewMem(slog.New(slog.NewJSONHandler(logs, nil)))
+		_, err := m.Set(t.Context(), Level{ProductID: "1", Available: 5, LowStock: 2})
+		require.NoError(t, err)
+
+		require.NoError(t, m.Reserve(t.Context(), "a", []Line{{ProductID: "1", Quantity: 2}}))
+		assert.Empty(t, logs.String())
+		require.NoError(t, m.Reserve(t.Context(), "b", []Line{{ProductID: "1", Quantity: 1}}))
+		assert.Contains(t, logs.String(), "product stock low")
+		logs.Reset()
+		require.NoError(t, m.Reserve(t.Context(), "c", []Line{{ProductID: "1", Quantity: 1}}))
+		assert.Empty(t, logs.String(), "only warn when first falling below the threshold")
+	})
+
+	t.Run("invalid level", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem(slog.New(slog.DiscardHandler))
+		_, err := m.Set(t.Context(), Level{Available: -1, LowStock: -1})
+		ae, ok := err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, apperr.CodeConstraint, ae.Code)
+		assert.EqualError(t, ae.Cause, "productId is required\navailable cannot be less than zero\nlowStock cannot be less than zero")
+	})
+
+	t.Run("concurrent reservations never oversell", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem(slog.New(slog.DiscardHandler))
+		_, err := m.Set(t.Context(), Level{ProductID: "1", Available: 10})
+		require.NoError(t, err)
+		var reserved atomic.Int32
+		wg := sync.WaitGroup{}
+		for i := range 50 {
+			wg.Go(func() {
+				if m.Reserve(t.Context(), string(rune('a'+i)), []Line{{ProductID: "1", Quantity: 3}}) == nil {
+					reserved.Add(1)
+				}
+			})
+		}
+		wg.Wait()
+		assert.Equal(t, int32(3), reserved.Load())
+		assert.Equal(t, 1, available(t, m, "1"))
+	})
+}
@@ -0,0 +1,166 @@
+// package locations contains the kart locations and the menu of products each of them sells.
+package locations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/categories"
+	"github.com/matgreaves/kart-challenge/api/products"
+)
+
+// DefaultID is the location served by routes, and used by orders, that don't name a location.
+const DefaultID = "default"
+
+// Location is a single kart location.
+type Location struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// Store is the interface for looking up locations and their menus.
+type Store interface {
+	// Get returns the location with id or an [apperr.CodeNotFound] error if there isn't one.
+	Get(ctx context.Context, id string) (Location, error)
+	// List returns every location ordered by ID.
+	List(ctx context.Context) ([]Location, error)
+	// Products returns the catalogue of products sold at the location with id or an
+	// [apperr.CodeNotFound] error if there isn't one.
+	Products(ctx context.Context, id string) (products.Store, error)
+}
+
+// Menu is a location and the catalogue of products it sells.
+type Menu struct {
+	Location
+	Products products.Store
+}
+
+var _ Store = &Mem{}
+
+// Mem is a [Store] of a fixed set of locations held in memory.
+type Mem struct {
+	menus map[string]Menu
+	ids   []string
+}
+
+// NewMem creates a [Mem] store of menus. Every menu must have a unique ID and a catalogue.
+func NewMem(menus ...Menu) (*Mem, error) {
+	m := &Mem{menus: map[string]Menu{}}
+	ve := []error{}
+	for i, v := range menus {
+		if v.ID == "" {
+			ve = append(ve, fmt.Errorf("location[%d] id is required", i))
+		} else if _, has := m.menus[v.ID]; has {
+			ve = append(ve, fmt.Errorf("location[%d] id %s is duplicated", i, v.ID))
+		}
+		if v.Products == nil {
+			ve = append(ve, fmt.Errorf("location[%d] products are required", i))
+		}
+		if _, has := m.menus[v.ID]; !has {
+			m.ids = append(m.ids, v.ID)
+		}
+		m.menus[v.ID] = v
+	}
+	if len(ve) > 0 {
+		return nil, errors.Join(ve...)
+	}
+	slices.Sort(m.ids)
+	return m, nil
+}
+
+// Get implements [Store.Get].
+func (m *Mem) Get(ctx context.Context, id string) (Location, error) {
+	menu, err := m.menu(ctx, id)
+	if err != nil {
+		return Location{}, err
+	}
+	return menu.Location, nil
+}
+
+// List implements [Store.List].
+func (m *Mem) List(ctx context.Context) ([]Location, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ls := make([]Location, 0, len(m.ids))
+	for _, id := range m.ids {
+		ls = append(ls, m.menus[id].Location)
+	}
+	return ls, nil
+}
+
+// Products implements [Store.Products].
+func (m *Mem) Products(ctx context.Context, id string) (products.Store, error) {
+	menu, err := m.menu(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return menu.Products, nil
+}
+
+func (m *Mem) menu(ctx context.Context, id string) (Menu, error) {
+	if err := ctx.Err(); err != nil {
+		return Menu{}, err
+	}
+	menu, has := m.menus[id]
+	if !has {
+		return Menu{}, apperr.NewError(apperr.CodeNotFound, fmt.Errorf("location %s not found", id))
+	}
+	return menu, nil
+}
+
+// OpenDir opens the catalogue of every location in dir with [products.OpenFile]. Each entry in
+// dir is a single location's catalogue, either a file or a directory of files, named after the
+// location's ID, and must only reference categories in cs. An entry named after [DefaultID], or
+// with a name that can't be used as a URL path segment as is, is rejected. Every catalogue is checked for changes
+// each interval until ctx is done, never when interval is zero or less.
+func OpenDir(ctx context.Context, dir string, cs []categories.Category, logger *slog.Logger, interval time.Duration) ([]Menu, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read locations: %w", err)
+	}
+	menus := []Menu{}
+	for _, v := range entries {
+		name := v.Name()
+		ext := strings.ToLower(filepath.Ext(name))
+		if !v.IsDir() && ext != ".json" && ext != ".csv" {
+			continue
+		}
+		id := name
+		if !v.IsDir() {
+			id = strings.TrimSuffix(name, filepath.Ext(name))
+		}
+		path := filepath.Join(dir, name)
+		if err := validateID(id); err != nil {
+			return nil, fmt.Errorf("invalid location %s: %w", path, err)
+		}
+		f, err := products.OpenFile(path, cs, logger.With(slog.String("location", id)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open location %s: %w", id, err)
+		}
+		go f.Watch(ctx, interval)
+		menus = append(menus, Menu{Location: Location{ID: id, Name: id}, Products: f})
+	}
+	return menus, nil
+}
+
+// validateID checks id can be used as the ID of a location opened from a file. It must be usable
+// in URLs without escaping and can't take the place of the default location.
+func validateID(id string) error {
+	if id == DefaultID {
+		return fmt.Errorf("id %s is reserved for the default location", id)
+	}
+	if id == "" || id == "." || id == ".." || url.PathEscape(id) != id {
+		return fmt.Errorf("id %q isn't a valid URL path segment", id)
+	}
+	return nil
+}
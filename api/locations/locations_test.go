@@ -0,0 +1,110 @@
+package locations
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/categories"
+	"github.com/matgreaves/kart-challenge/api/money"
+	"github.com/matgreaves/kart-challenge/api/products"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMem(t *testing.T) {
+	t.Parallel()
+
+	city := products.Slice{{ID: "1", Name: "Waffle", Price: money.New(800, "AUD")}}
+	beach := products.Slice{{ID: "2", Name: "Gelato", Price: money.New(500, "AUD")}}
+
+	t.Run("looks up locations", func(t *testing.T) {
+		t.Parallel()
+		m, err := NewMem(
+			Menu{Location: Location{ID: "city", Name: "City"}, Products: city},
+			Menu{Location: Location{ID: "beach", Name: "Beach"}, Products: beach},
+		)
+		require.NoError(t, err)
+
+		l, err := m.Get(t.Context(), "city")
+		require.NoError(t, err)
+		assert.Equal(t, Location{ID: "city", Name: "City"}, l)
+
+		ls, err := m.List(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, []Location{{ID: "beach", Name: "Beach"}, {ID: "city", Name: "City"}}, ls)
+
+		ps, err := m.Products(t.Context(), "beach")
+		require.NoError(t, err)
+		assert.Equal(t, beach, ps)
+	})
+
+	t.Run("no location", func(t *testing.T) {
+		t.Parallel()
+		m, err := NewMem()
+		require.NoError(t, err)
+
+		_, err = m.Get(t.Context(), "moon")
+		ae, ok := err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, apperr.CodeNotFound, ae.Code)
+		assert.ErrorContains(t, err, "location moon not found")
+
+		_, err = m.Products(t.Context(), "moon")
+		ae, ok = err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, apperr.CodeNotFound, ae.Code)
+	})
+
+	t.Run("invalid menus", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewMem(
+			Menu{Location: Location{ID: "city"}, Products: city},
+			Menu{Location: Location{ID: "city"}, Products: beach},
+			Menu{Products: beach},
+			Menu{Location: Location{ID: "beach"}},
+		)
+		assert.ErrorContains(t, err, "location[1] id city is duplicated")
+		assert.ErrorContains(t, err, "location[2] id is required")
+		assert.ErrorContains(t, err, "location[3] products are required")
+	})
+}
+
+func TestOpenDir(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cs := []categories.Category{{ID: "waffle", Name: "Waffles"}, {ID: "gelato", Name: "Gelato"}}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "city.csv"), []byte("id,name,category,price\n1,Waffle,Waffle,8.00\n"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "beach"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "beach", "menu.json"), []byte(`[{"id":"2","name":"Gelato","category":"Gelato","price":5}]`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0o644))
+
+	menus, err := OpenDir(t.Context(), dir, cs, slog.New(slog.DiscardHandler), products.DefaultReloadInterval)
+	require.NoError(t, err)
+	require.Len(t, menus, 2)
+	assert.Equal(t, Location{ID: "beach", Name: "beach"}, menus[0].Location)
+	assert.Equal(t, Location{ID: "city", Name: "city"}, menus[1].Location)
+	p, err := menus[1].Products.Get(t.Context(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, money.New(800, "AUD"), p.Price)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "empty.json"), []byte(`[]`), 0o644))
+	_, err = OpenDir(t.Context(), dir, cs, slog.New(slog.DiscardHandler), products.DefaultReloadInterval)
+	assert.ErrorContains(t, err, "failed to open location empty")
+
+	for name, want := range map[string]string{
+		"default.csv":     "id default is reserved for the default location",
+		"north beach.csv": `id "north beach" isn't a valid URL path segment`,
+		"100%.json":       `id "100%" isn't a valid URL path segment`,
+		"what?.csv":       `id "what?" isn't a valid URL path segment`,
+		".json":           `id "" isn't a valid URL path segment`,
+	} {
+		bad := t.TempDir()
+		path := filepath.Join(bad, name)
+		require.NoError(t, os.WriteFile(path, []byte("id,name,category,price\n1,Waffle,Waffle,8.00\n"), 0o644))
+		_, err = OpenDir(t.Context(), bad, cs, slog.New(slog.DiscardHandler), products.DefaultReloadInterval)
+		assert.EqualError(t, err, "invalid location "+path+": "+want, name)
+	}
+}
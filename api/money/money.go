@@ -0,0 +1,210 @@
+// package money contains an exact representation of monetary amounts.
+package money
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// DefaultCurrency is the currency given to amounts decoded without one, such as the numeric prices
+// in our product data.
+const DefaultCurrency = "AUD"
+
+var (
+	ErrCurrencyMismatch = errors.New("money: currencies do not match")
+	ErrOverflow         = errors.New("money: amount out of range")
+)
+
+// minorDigits lists ISO 4217 currencies that don't use two decimal places for their minor unit.
+var minorDigits = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+	"BHD": 3,
+	"KWD": 3,
+	"OMR": 3,
+}
+
+// Money is an exact amount of a currency held in the currency's minor unit, cents for AUD.
+//
+// The zero Money is zero in any currency so can be used as the starting point of a sum.
+type Money struct {
+	// Amount in minor units.
+	Amount int64
+	// Currency is an ISO 4217 currency code.
+	Currency string
+}
+
+// New returns minor units of currency.
+func New(minor int64, currency string) Money {
+	return Money{Amount: minor, Currency: currency}
+}
+
+// Parse parses a decimal amount in major units of currency such as "6.50".
+//
+// Amounts with more precision than the currency's minor unit are rejected rather than rounded.
+func Parse(s, currency string) (Money, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Money{}, fmt.Errorf("money: invalid amount %q", s)
+	}
+	r.Mul(r, new(big.Rat).SetInt(scale(currency)))
+	if !r.IsInt() {
+		return Money{}, fmt.Errorf("money: amount %q has more precision than %s allows", s, currency)
+	}
+	if !r.Num().IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: r.Num().Int64(), Currency: currency}, nil
+}
+
+// IsZero reports whether m is zero in any currency.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Cmp compares m and o returning -1, 0 or +1. Zero is comparable with any currency.
+func (m Money) Cmp(o Money) (int, error) {
+	if _, err := currency(m, o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Add returns m + o.
+func (m Money) Add(o Money) (Money, error) {
+	c, err := currency(m, o)
+	if err != nil {
+		return Money{}, err
+	}
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: sum, Currency: c}, nil
+}
+
+// Sub returns m - o.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return m.Add(Money{Amount: -o.Amount, Currency: o.Currency})
+}
+
+// Mul returns m multiplied by n, useful for pricing a quantity of items.
+func (m Money) Mul(n int64) (Money, error) {
+	if n == 0 || m.Amount == 0 {
+		return Money{Currency: m.Currency}, nil
+	}
+	p := m.Amount * n
+	if p/n != m.Amount || (m.Amount == -1 && n == math.MinInt64) || (n == -1 && m.Amount == math.MinInt64) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: p, Currency: m.Currency}, nil
+}
+
+// Percent returns pct percent of m rounded to the nearest minor unit, halves rounded away from zero.
+func (m Money) Percent(pct float64) (Money, error) {
+	if math.IsNaN(pct) || math.IsInf(pct, 0) {
+		return Money{}, fmt.Errorf("money: invalid percentage %v", pct)
+	}
+	r := new(big.Rat).SetInt64(m.Amount)
+	r.Mul(r, new(big.Rat).SetFloat64(pct))
+	r.Quo(r, big.NewRat(100, 1))
+
+	// round half away from zero
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	}
+	if !q.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: q.Int64(), Currency: m.Currency}, nil
+}
+
+// Min returns the smaller of m and o.
+func (m Money) Min(o Money) (Money, error) {
+	c, err := m.Cmp(o)
+	if err != nil {
+		return Money{}, err
+	}
+	if c <= 0 {
+		return m, nil
+	}
+	return o, nil
+}
+
+// Decimal formats m in major units without trailing zeros, "6.5" for 650 cents.
+func (m Money) Decimal() string {
+	s := new(big.Rat).SetFrac(big.NewInt(m.Amount), scale(m.Currency)).FloatString(digits(m.Currency))
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// String implements [fmt.Stringer].
+func (m Money) String() string {
+	c := m.Currency
+	if c == "" {
+		c = DefaultCurrency
+	}
+	return c + " " + new(big.Rat).SetFrac(big.NewInt(m.Amount), scale(c)).FloatString(digits(c))
+}
+
+// MarshalJSON implements [json.Marshaler] encoding m as a number in major units to stay compatible
+// with clients that expect prices to be plain numbers.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.Decimal()), nil
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] decoding a number in major units of [DefaultCurrency].
+func (m *Money) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+	v, err := Parse(string(b), DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// currency returns the currency shared by a and b treating zero as any currency.
+func currency(a, b Money) (string, error) {
+	switch {
+	case a.Currency == b.Currency:
+		return a.Currency, nil
+	case a.IsZero() && a.Currency == "":
+		return b.Currency, nil
+	case b.IsZero() && b.Currency == "":
+		return a.Currency, nil
+	case a.IsZero() && b.IsZero():
+		return a.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, a.Currency, b.Currency)
+}
+
+func digits(currency string) int {
+	if d, has := minorDigits[currency]; has {
+		return d
+	}
+	return 2
+}
+
+func scale(currency string) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits(currency))), nil)
+}
@@ -0,0 +1,159 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func aud(minor int64) Money {
+	return New(minor, "AUD")
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		in       string
+		currency string
+		want     Money
+		wantErr  string
+	}{
+		{in: "6.5", currency: "AUD", want: aud(650)},
+		{in: "0.1", currency: "AUD", want: aud(10)},
+		{in: "7", currency: "AUD", want: aud(700)},
+		{in: "-1.25", currency: "AUD", want: aud(-125)},
+		{in: "1e2", currency: "AUD", want: aud(10000)},
+		{in: "500", currency: "JPY", want: New(500, "JPY")},
+		{in: "1.005", currency: "AUD", wantErr: `amount "1.005" has more precision than AUD allows`},
+		{in: "1.5", currency: "JPY", wantErr: `amount "1.5" has more precision than JPY allows`},
+		{in: "abc", currency: "AUD", wantErr: `invalid amount "abc"`},
+		{in: "1e30", currency: "AUD", wantErr: "amount out of range"},
+	} {
+		t.Run(tc.in+" "+tc.currency, func(t *testing.T) {
+			t.Parallel()
+			got, err := Parse(tc.in, tc.currency)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMoney_Add(t *testing.T) {
+	t.Parallel()
+
+	sum, err := Money{}.Add(aud(10))
+	require.NoError(t, err)
+	assert.Equal(t, aud(10), sum)
+
+	sum, err = sum.Add(aud(20))
+	require.NoError(t, err)
+	assert.Equal(t, aud(30), sum)
+
+	_, err = aud(10).Add(New(10, "USD"))
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	_, err = aud(math.MaxInt64).Add(aud(1))
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func TestMoney_Sub(t *testing.T) {
+	t.Parallel()
+
+	d, err := aud(30).Sub(aud(45))
+	require.NoError(t, err)
+	assert.Equal(t, aud(-15), d)
+
+	_, err = aud(-2).Sub(aud(math.MaxInt64))
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func TestMoney_Mul(t *testing.T) {
+	t.Parallel()
+
+	p, err := aud(10).Mul(3)
+	require.NoError(t, err)
+	assert.Equal(t, aud(30), p)
+
+	p, err = aud(10).Mul(0)
+	require.NoError(t, err)
+	assert.Equal(t, aud(0), p)
+
+	_, err = aud(math.MaxInt64 / 2).Mul(3)
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func TestMoney_Percent(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		m    Money
+		pct  float64
+		want Money
+	}{
+		{m: aud(2400), pct: 10, want: aud(240)},
+		{m: aud(450), pct: 15, want: aud(68)},
+		{m: aud(-450), pct: 15, want: aud(-68)},
+		{m: aud(333), pct: 12.5, want: aud(42)},
+		{m: aud(100), pct: 0, want: aud(0)},
+	} {
+		got, err := tc.m.Percent(tc.pct)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, got, "%v%% of %s", tc.pct, tc.m)
+	}
+
+	_, err := aud(100).Percent(math.NaN())
+	assert.ErrorContains(t, err, "invalid percentage")
+}
+
+func TestMoney_Min(t *testing.T) {
+	t.Parallel()
+
+	m, err := aud(10).Min(aud(5))
+	require.NoError(t, err)
+	assert.Equal(t, aud(5), m)
+
+	_, err = aud(10).Min(New(5, "USD"))
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+}
+
+func TestMoney_String(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "AUD 6.50", aud(650).String())
+	assert.Equal(t, "JPY 500", New(500, "JPY").String())
+	assert.Equal(t, "AUD 0.00", Money{}.String())
+}
+
+func TestMoney_JSON(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		m    Money
+		want string
+	}{
+		{m: aud(650), want: "6.5"},
+		{m: aud(700), want: "7"},
+		{m: aud(5), want: "0.05"},
+		{m: aud(-125), want: "-1.25"},
+		{m: Money{}, want: "0"},
+	} {
+		b, err := json.Marshal(tc.m)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, string(b))
+
+		var got Money
+		require.NoError(t, json.Unmarshal(b, &got))
+		assert.Equal(t, tc.m.Amount, got.Amount)
+		assert.Equal(t, DefaultCurrency, got.Currency)
+	}
+
+	var m Money
+	assert.ErrorContains(t, json.Unmarshal([]byte(`"6.5"`), &m), "invalid amount")
+}
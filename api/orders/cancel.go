@@ -0,0 +1,131 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/txn"
+)
+
+// maxCancelNote is the longest note that can be given with a [CancelReq].
+const maxCancelNote = 500
+
+// CancelReason is the reason code given when cancelling an [Order].
+type CancelReason string
+
+const (
+	CancelCustomerRequest CancelReason = "customer_request"
+	CancelDuplicateOrder  CancelReason = "duplicate_order"
+	CancelPaymentFailed   CancelReason = "payment_failed"
+	CancelOutOfStock      CancelReason = "out_of_stock"
+	CancelStoreClosed     CancelReason = "store_closed"
+	CancelOther           CancelReason = "other"
+)
+
+var cancelReasons = map[CancelReason]struct{}{
+	CancelCustomerRequest: {},
+	CancelDuplicateOrder:  {},
+	CancelPaymentFailed:   {},
+	CancelOutOfStock:      {},
+	CancelStoreClosed:     {},
+	CancelOther:           {},
+}
+
+// CancelReq asks for an [Order] to be cancelled.
+type CancelReq struct {
+	Reason CancelReason `json:"reason"`
+	// Note is optional free text explaining the cancellation.
+	Note string `json:"note,omitempty"`
+}
+
+// Validate checks whether c is well formed.
+func (c CancelReq) Validate() error {
+	ve := []error{}
+	if c.Reason == "" {
+		ve = append(ve, errors.New("reason is required"))
+	} else if _, has := cancelReasons[c.Reason]; !has {
+		ve = append(ve, fmt.Errorf("unknown reason %q", c.Reason))
+	}
+	if utf8.RuneCountInString(c.Note) > maxCancelNote {
+		ve = append(ve, fmt.Errorf("note must be at most %d characters", maxCancelNote))
+	}
+	if len(ve) > 0 {
+		return errors.Join(ve...)
+	}
+	return nil
+}
+
+// Cancellation records who cancelled an [Order], when and why.
+type Cancellation struct {
+	Reason CancelReason `json:"reason"`
+	Note   string       `json:"note,omitempty"`
+	At     time.Time    `json:"at"`
+	// By identifies the principal that cancelled the order.
+	By string `json:"by"`
+}
+
+// Cancel cancels the order with id on behalf of actor. Orders can only be cancelled before they
+// start being prepared.
+//
+// Any use of a limited use coupon made by the order is released so it can be used again, as is
+// any stock reserved for the order.
+func (s Service) Cancel(ctx context.Context, id string, req CancelReq, actor string) (Order, error) {
+	if err := req.Validate(); err != nil {
+		return Order{}, apperr.NewError(apperr.CodeConstraint, err)
+	}
+	// the order is put back as it was if its coupon or stock can't be released
+	var o Order
+	err := txn.Run(ctx, func(ctx context.Context) error {
+		var err error
+		o, err = s.Orders.Update(ctx, id, cancel(req, actor))
+		if err != nil {
+			return err
+		}
+		return s.release(ctx, o)
+	})
+	if err != nil {
+		return Order{}, err
+	}
+	s.publishSaved(ctx, EventStatusChanged, o)
+	return o, nil
+}
+
+// cancel returns an update cancelling an order for req on behalf of actor.
+func cancel(req CancelReq, actor string) func(Order) (Order, error) {
+	return func(o Order) (Order, error) {
+		if !CanTransition(o.Status, StatusCancelled) {
+			return Order{}, apperr.NewError(apperr.CodeConstraint, fmt.Errorf("order can no longer be cancelled once %s", o.Status))
+		}
+		o, err := transition(o, StatusCancelled, actor)
+		if err != nil {
+			return Order{}, err
+		}
+		o.Cancellation = &Cancellation{
+			Reason: req.Reason,
+			Note:   req.Note,
+			At:     o.History[len(o.History)-1].At,
+			By:     actor,
+		}
+		return o, nil
+	}
+}
+
+// release releases any use of a limited use coupon and any stock reserved by o, which has been
+// cancelled or rejected so will never be fulfilled.
+func (s Service) release(ctx context.Context, o Order) error {
+	if o.Coupon != nil && o.Coupon.Applied && s.Redemptions != nil {
+		if err := s.Redemptions.Release(ctx, o.Coupon.Code, o.ID); err != nil {
+			return fmt.Errorf("order %s failed to release coupon: %w", o.ID, err)
+		}
+	}
+	if s.Inventory != nil {
+		if err := s.Inventory.Release(ctx, o.ID); err != nil {
+			return fmt.Errorf("order %s failed to release stock: %w", o.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/coupons"
+	"github.com/matgreaves/kart-challenge/api/inventory"
+	"github.com/matgreaves/kart-challenge/api/products"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancel(t *testing.T) {
+	t.Parallel()
+
+	t.Run("placed order", func(t *testing.T) {
+		t.Parallel()
+		s := Service{Orders: NewMem(), Products: products.NewSlice(products.SampleData)}
+		o, err := s.Create(t.Context(), "customer", testReq())
+		require.NoError(t, err)
+
+		o, err = s.Cancel(t.Context(), o.ID, CancelReq{Reason: CancelCustomerRequest, Note: "changed my mind"}, "customer")
+		require.NoError(t, err)
+		assert.Equal(t, StatusCancelled, o.Status)
+		require.NotNil(t, o.Cancellation)
+		assert.Equal(t, CancelCustomerRequest, o.Cancellation.Reason)
+		assert.Equal(t, "changed my mind", o.Cancellation.Note)
+		assert.Equal(t, "customer", o.Cancellation.By)
+		assert.False(t, o.Cancellation.At.IsZero())
+		assert.Equal(t, o.History[len(o.History)-1].At, o.Cancellation.At)
+	})
+
+	t.Run("already preparing", func(t *testing.T) {
+		t.Parallel()
+		s := Service{Orders: NewMem(), Products: products.NewSlice(products.SampleData)}
+		o, err := s.Create(t.Context(), "customer", testReq())
+		require.NoError(t, err)
+		for _, to := range []Status{StatusAccepted, StatusPreparing} {
+			_, err = s.Transition(t.Context(), o.ID, to, "staff")
+			require.NoError(t, err)
+		}
+
+		_, err = s.Cancel(t.Context(), o.ID, CancelReq{Reason: CancelCustomerRequest}, "customer")
+		ae, ok := err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, apperr.CodeConstraint, ae.Code)
+		assert.ErrorContains(t, err, "order can no longer be cancelled once preparing")
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		t.Parallel()
+		_, err := Service{}.Cancel(t.Context(), "1", CancelReq{Reason: "bored"}, "customer")
+		ae, ok := err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, apperr.CodeConstraint, ae.Code)
+		assert.ErrorContains(t, err, `unknown reason "bored"`)
+
+		_, err = Service{}.Cancel(t.Context(), "1", CancelReq{}, "customer")
+		assert.ErrorContains(t, err, "reason is required")
+	})
+
+	t.Run("releases limited use coupon", func(t *testing.T) {
+		t.Parallel()
+		cs, err := coupons.NewRules(coupons.Coupon{Code: "ONCE", Kind: coupons.KindPercentage, Percent: 10, Limits: coupons.Limits{MaxRedemptions: 1}})
+		require.NoError(t, err)
+		s := Service{
+			Orders:      NewMem(),
+			Products:    products.NewSlice(products.SampleData),
+			Coupons:     cs,
+			Redemptions: coupons.NewMemRedemptions(),
+		}
+		req := testReq()
+		req.CouponCode = "ONCE"
+
+		first, err := s.Create(t.Context(), "customer", req)
+		require.NoError(t, err)
+		_, err = s.Create(t.Context(), "customer", req)
+		assert.ErrorContains(t, err, "coupon ONCE has no uses left")
+
+		_, err = s.Cancel(t.Context(), first.ID, CancelReq{Reason: CancelDuplicateOrder}, "customer")
+		require.NoError(t, err)
+		_, err = s.Create(t.Context(), "customer", req)
+		assert.NoError(t, err)
+	})
+
+	t.Run("releases stock", func(t *testing.T) {
+		t.Parallel()
+		inv := inventory.NewMem(slog.New(slog.DiscardHandler))
+		_, err := inv.Set(t.Context(), inventory.Level{ProductID: "1", Available: 1})
+		require.NoError(t, err)
+		s := Service{
+			Orders:    NewMem(),
+			Products:  products.NewSlice(products.SampleData),
+			Inventory: inv,
+		}
+
+		first, err := s.Create(t.Context(), "customer", testReq())
+		require.NoError(t, err)
+		_, err = s.Create(t.Context(), "customer", testReq())
+		assert.ErrorContains(t, err, "item[0] only 0 of product 1 in stock")
+
+		_, err = s.Cancel(t.Context(), first.ID, CancelReq{Reason: CancelOutOfStock}, "staff")
+		require.NoError(t, err)
+		_, err = s.Create(t.Context(), "customer", testReq())
+		assert.NoError(t, err)
+	})
+
+	t.Run("failed release keeps order", func(t *testing.T) {
+		t.Parallel()
+		s := releaseFailingService(t)
+		o, err := s.Create(t.Context(), "customer", onceReq())
+		require.NoError(t, err)
+
+		_, err = s.Cancel(t.Context(), o.ID, CancelReq{Reason: CancelCustomerRequest}, "customer")
+		assert.ErrorContains(t, err, "order "+o.ID+" failed to release stock: stock unavailable")
+
+		stored, err := s.Orders.Get(t.Context(), o.ID)
+		require.NoError(t, err)
+		assert.Equal(t, o, stored)
+		_, err = s.Create(t.Context(), "customer", onceReq())
+		assert.ErrorContains(t, err, "coupon ONCE has no uses left", "released coupon is taken back")
+	})
+}
+
+// failingRelease is an [inventory.Store] that can't release stock.
+type failingRelease struct {
+	inventory.Store
+}
+
+func (failingRelease) Release(context.Context, string) error {
+	return errors.New("stock unavailable")
+}
+
+// releaseFailingService returns a [Service] with a single use coupon, ONCE, that can't release
+// stock.
+func releaseFailingService(t *testing.T) Service {
+	t.Helper()
+	cs, err := coupons.NewRules(coupons.Coupon{Code: "ONCE", Kind: coupons.KindPercentage, Percent: 10, Limits: coupons.Limits{MaxRedemptions: 1}})
+	require.NoError(t, err)
+	return Service{
+		Orders:      NewMem(),
+		Products:    products.NewSlice(products.SampleData),
+		Coupons:     cs,
+		Redemptions: coupons.NewMemRedemptions(),
+		Inventory:   failingRelease{inventory.NewMem(slog.New(slog.DiscardHandler))},
+	}
+}
+
+// onceReq returns a test order using the ONCE coupon.
+func onceReq() OrderReq {
+	req := testReq()
+	req.CouponCode = "ONCE"
+	return req
+}
@@ -0,0 +1,96 @@
+package orders
+
+import (
+	"fmt"
+
+	"github.com/matgreaves/kart-challenge/api/coupons"
+	"github.com/matgreaves/kart-challenge/api/money"
+	"github.com/matgreaves/kart-challenge/api/products"
+)
+
+// CouponResult reports the effect the coupon requested with an [OrderReq] had on the [Order].
+type CouponResult struct {
+	Code    string `json:"code"`
+	Applied bool   `json:"applied"`
+	// Reason explains why the coupon was not applied.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Discount calculates the amount c takes off an order of items where prods[i] is the product
+// ordered by items[i].
+//
+// When c doesn't apply to the order discount is zero and reason explains why.
+func Discount(c coupons.Coupon, items []OrderItem, prods []products.Product) (discount money.Money, reason string) {
+	off, reason, err := couponDiscount(c, items, prods)
+	if err != nil {
+		return money.Money{}, fmt.Sprintf("coupon could not be applied: %s", err)
+	}
+	return off, reason
+}
+
+func couponDiscount(c coupons.Coupon, items []OrderItem, prods []products.Product) (money.Money, string, error) {
+	if len(items) != len(prods) {
+		return money.Money{}, "", fmt.Errorf("%d items priced with %d products", len(items), len(prods))
+	}
+
+	var subtotal, eligible, off money.Money
+	for i, v := range items {
+		unit := prods[i].Price
+		line, err := unit.Mul(int64(v.Quantity))
+		if err != nil {
+			return money.Money{}, "", err
+		}
+		if subtotal, err = subtotal.Add(line); err != nil {
+			return money.Money{}, "", err
+		}
+		if !c.Eligible(prods[i].ID, prods[i].Category) {
+			continue
+		}
+		if eligible, err = eligible.Add(line); err != nil {
+			return money.Money{}, "", err
+		}
+
+		switch c.Kind {
+		case coupons.KindBuyXGetY:
+			free, err := unit.Mul(int64(v.Quantity / (c.Buy + c.Get) * c.Get))
+			if err != nil {
+				return money.Money{}, "", err
+			}
+			if off, err = off.Add(free); err != nil {
+				return money.Money{}, "", err
+			}
+		case coupons.KindFreeItem:
+			if v.ProductID == c.FreeProductID && v.Quantity > 0 && off.IsZero() {
+				off = unit
+			}
+		}
+	}
+
+	if cmp, err := subtotal.Cmp(c.MinSpend); err != nil {
+		return money.Money{}, "", err
+	} else if cmp < 0 {
+		return money.Money{}, fmt.Sprintf("order subtotal must be at least %s", c.MinSpend), nil
+	}
+	if eligible.IsZero() && c.Kind != coupons.KindFreeItem {
+		return money.Money{}, "no items in the order are eligible for the coupon", nil
+	}
+
+	var err error
+	switch c.Kind {
+	case coupons.KindPercentage:
+		off, err = eligible.Percent(c.Percent)
+	case coupons.KindFixed:
+		off, err = c.Amount.Min(eligible)
+	case coupons.KindBuyXGetY:
+		if off.IsZero() {
+			return money.Money{}, fmt.Sprintf("order must contain at least %d of an eligible item", c.Buy+c.Get), nil
+		}
+	case coupons.KindFreeItem:
+		if off.IsZero() {
+			return money.Money{}, fmt.Sprintf("product %s must be in the order", c.FreeProductID), nil
+		}
+	default:
+		return money.Money{}, fmt.Sprintf("coupon kind %q is not supported", c.Kind), nil
+	}
+	return off, "", err
+}
@@ -0,0 +1,85 @@
+package orders
+
+import (
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/coupons"
+	"github.com/matgreaves/kart-challenge/api/money"
+	"github.com/matgreaves/kart-challenge/api/products"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscount(t *testing.T) {
+	t.Parallel()
+
+	items := []OrderItem{{ProductID: "1", Quantity: 3}, {ProductID: "2", Quantity: 1}}
+	prods := []products.Product{
+		{ID: "1", Category: "Waffle", Price: aud(650)},
+		{ID: "2", Category: "Cake", Price: aud(450)},
+	}
+
+	for _, tc := range []struct {
+		name       string
+		coupon     coupons.Coupon
+		wantOff    money.Money
+		wantReason string
+	}{
+		{
+			name:    "default",
+			coupon:  coupons.Default("OVER9000"),
+			wantOff: aud(240),
+		},
+		{
+			name:    "percentage of eligible category",
+			coupon:  coupons.Coupon{Kind: coupons.KindPercentage, Percent: 15, Categories: []string{"Cake"}},
+			wantOff: aud(68),
+		},
+		{
+			name:    "fixed",
+			coupon:  coupons.Coupon{Kind: coupons.KindFixed, Amount: aud(500)},
+			wantOff: aud(500),
+		},
+		{
+			name:    "fixed capped at eligible items",
+			coupon:  coupons.Coupon{Kind: coupons.KindFixed, Amount: aud(500), ProductIDs: []string{"2"}},
+			wantOff: aud(450),
+		},
+		{
+			name:    "buy two get one",
+			coupon:  coupons.Coupon{Kind: coupons.KindBuyXGetY, Buy: 2, Get: 1},
+			wantOff: aud(650),
+		},
+		{
+			name:       "buy three get one without enough items",
+			coupon:     coupons.Coupon{Kind: coupons.KindBuyXGetY, Buy: 3, Get: 1},
+			wantReason: "order must contain at least 4 of an eligible item",
+		},
+		{
+			name:    "free item",
+			coupon:  coupons.Coupon{Kind: coupons.KindFreeItem, FreeProductID: "2"},
+			wantOff: aud(450),
+		},
+		{
+			name:       "free item not in order",
+			coupon:     coupons.Coupon{Kind: coupons.KindFreeItem, FreeProductID: "3"},
+			wantReason: "product 3 must be in the order",
+		},
+		{
+			name:       "below minimum spend",
+			coupon:     coupons.Coupon{Kind: coupons.KindFixed, Amount: aud(500), MinSpend: aud(3000)},
+			wantReason: "order subtotal must be at least AUD 30.00",
+		},
+		{
+			name:       "no eligible items",
+			coupon:     coupons.Coupon{Kind: coupons.KindPercentage, Percent: 10, Categories: []string{"Pie"}},
+			wantReason: "no items in the order are eligible for the coupon",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			off, reason := Discount(tc.coupon, items, prods)
+			assert.Equal(t, tc.wantOff, off)
+			assert.Equal(t, tc.wantReason, reason)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package orders
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventType describes what happened to an [Order] in an [Event].
+type EventType string
+
+const (
+	// EventCreated is published when an order is placed.
+	EventCreated EventType = "order.created"
+	// EventStatusChanged is published whenever an order moves to a new [Status], including
+	// when it's cancelled.
+	EventStatusChanged EventType = "order.status_changed"
+)
+
+// EventTypes lists every [EventType].
+var EventTypes = []EventType{EventCreated, EventStatusChanged}
+
+// Event is published whenever an [Order] is created or changed.
+type Event struct {
+	ID   string    `json:"id"`
+	Type EventType `json:"type"`
+	At   time.Time `json:"at"`
+	// Order is the order as it was straight after the event.
+	Order Order `json:"order"`
+}
+
+// Publisher is the interface for recording events so they can be delivered to interested parties.
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// publish records an event of type t for o if s has somewhere to publish events to.
+func (s Service) publish(ctx context.Context, t EventType, o Order) error {
+	if s.Events == nil {
+		return nil
+	}
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return fmt.Errorf("orders failed to create event uuid: %w", err)
+	}
+	err = s.Events.Publish(ctx, Event{
+		ID:    id.String(),
+		Type:  t,
+		At:    time.Now().UTC(),
+		Order: o,
+	})
+	if err != nil {
+		return fmt.Errorf("order %s failed to publish %s event: %w", o.ID, t, err)
+	}
+	return nil
+}
+
+// publishSaved publishes an event of type t for o once the change to o has been saved. Failures
+// are logged rather than returned, the change has been made so a retry would be rejected.
+func (s Service) publishSaved(ctx context.Context, t EventType, o Order) {
+	if err := s.publish(ctx, t, o); err != nil {
+		logger := s.Logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+		logger.ErrorContext(ctx, "failed to publish order event", slog.String("orderId", o.ID), slog.String("error", err.Error()))
+	}
+}
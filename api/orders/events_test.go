@@ -0,0 +1,90 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/products"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a [Publisher] that keeps every event published to it.
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) Publish(_ context.Context, e Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+// failing is a [Publisher] that can't publish anything.
+type failing struct{}
+
+func (failing) Publish(context.Context, Event) error {
+	return errors.New("outbox unavailable")
+}
+
+func TestService_publish(t *testing.T) {
+	t.Parallel()
+
+	r := &recorder{}
+	s := Service{Orders: NewMem(), Products: products.NewSlice(products.SampleData), Events: r}
+
+	o, err := s.Create(t.Context(), "customer", testReq())
+	require.NoError(t, err)
+	accepted, err := s.Transition(t.Context(), o.ID, StatusAccepted, "staff")
+	require.NoError(t, err)
+	cancelled, err := s.Cancel(t.Context(), o.ID, CancelReq{Reason: CancelStoreClosed}, "staff")
+	require.NoError(t, err)
+
+	require.Len(t, r.events, 3)
+	for i, want := range []struct {
+		t EventType
+		o Order
+	}{
+		{EventCreated, o},
+		{EventStatusChanged, accepted},
+		{EventStatusChanged, cancelled},
+	} {
+		assert.NotEmpty(t, r.events[i].ID)
+		assert.False(t, r.events[i].At.IsZero())
+		assert.Equal(t, want.t, r.events[i].Type)
+		assert.Equal(t, want.o, r.events[i].Order)
+	}
+
+	_, err = s.Transition(t.Context(), o.ID, StatusAccepted, "staff")
+	require.Error(t, err)
+	assert.Len(t, r.events, 3, "failed changes must not publish events")
+}
+
+func TestService_publishSaved(t *testing.T) {
+	t.Parallel()
+
+	s := Service{Orders: NewMem(), Products: products.NewSlice(products.SampleData), Logger: slog.New(slog.DiscardHandler)}
+	o, err := s.Create(t.Context(), "customer", testReq())
+	require.NoError(t, err)
+
+	// once a change is saved it's returned even when its event can't be published
+	s.Events = failing{}
+	accepted, err := s.Transition(t.Context(), o.ID, StatusAccepted, "staff")
+	require.NoError(t, err)
+	assert.Equal(t, StatusAccepted, accepted.Status)
+	cancelled, err := s.Cancel(t.Context(), o.ID, CancelReq{Reason: CancelStoreClosed}, "staff")
+	require.NoError(t, err)
+	assert.Equal(t, StatusCancelled, cancelled.Status)
+
+	stored, err := s.Orders.Get(t.Context(), o.ID)
+	require.NoError(t, err)
+	assert.Equal(t, cancelled, stored)
+
+	_, err = s.Create(t.Context(), "customer", testReq())
+	assert.ErrorContains(t, err, "outbox unavailable", "a new order is rolled back")
+}
@@ -0,0 +1,320 @@
+package orders
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+)
+
+// Format is a file format orders can be exported to and imported from.
+type Format string
+
+const (
+	// FormatJSONL writes one JSON encoded order per line, the same encoding used by the API.
+	FormatJSONL Format = "jsonl"
+	// FormatCSV writes one order per row after a header row naming the columns. Columns holding a
+	// list or an object, such as items, hold the value JSON encoded.
+	FormatCSV Format = "csv"
+)
+
+// Columns lists every column that can be exported, named after the JSON field it holds, in the
+// order they're exported.
+var Columns = []string{
+	"id", "createdAt", "createdBy", "customerId", "locationId", "status", "items", "products", "lines",
+	"subtotal", "discount", "total", "coupon", "cancellation", "history",
+}
+
+// stringColumns are the [Columns] holding JSON strings, they're written to CSV unquoted.
+var stringColumns = map[string]bool{"id": true, "createdAt": true, "createdBy": true, "customerId": true, "locationId": true, "status": true}
+
+// ExportOptions select the orders and columns written by [Export].
+type ExportOptions struct {
+	Format Format
+	// CreatedFrom, when non zero, excludes orders created before it.
+	CreatedFrom time.Time
+	// CreatedTo, when non zero, excludes orders created at or after it.
+	CreatedTo time.Time
+	// Columns selects which of [Columns] to write and in what order, every column when empty.
+	// An export is only lossless when every column is written.
+	Columns []string
+}
+
+// Validate checks whether opts is well formed.
+func (opts ExportOptions) Validate() error {
+	ve := []error{}
+	if err := validFormat(opts.Format); err != nil {
+		ve = append(ve, err)
+	}
+	seen := map[string]bool{}
+	for _, v := range opts.Columns {
+		if !slices.Contains(Columns, v) {
+			ve = append(ve, fmt.Errorf("unknown column %q", v))
+		} else if seen[v] {
+			ve = append(ve, fmt.Errorf("column %q is duplicated", v))
+		}
+		seen[v] = true
+	}
+	if !opts.CreatedFrom.IsZero() && !opts.CreatedTo.IsZero() && !opts.CreatedFrom.Before(opts.CreatedTo) {
+		ve = append(ve, errors.New("createdFrom must be before createdTo"))
+	}
+	if len(ve) > 0 {
+		return apperr.NewError(apperr.CodeValidation, errors.Join(ve...))
+	}
+	return nil
+}
+
+// Export streams every order in s matching opts to w in list order, see [ListQuery]. Returns the
+// number of orders written.
+func Export(ctx context.Context, w io.Writer, s Store, opts ExportOptions) (int, error) {
+	if err := opts.Validate(); err != nil {
+		return 0, err
+	}
+	columns := opts.Columns
+	if len(columns) == 0 {
+		columns = Columns
+	}
+	bw := bufio.NewWriter(w)
+	cw := csv.NewWriter(bw)
+	if opts.Format == FormatCSV {
+		if err := cw.Write(columns); err != nil {
+			return 0, fmt.Errorf("failed to write header: %w", err)
+		}
+	}
+	n := 0
+	q := ListQuery{CreatedFrom: opts.CreatedFrom, CreatedTo: opts.CreatedTo, Limit: MaxListLimit}
+	for {
+		p, err := s.List(ctx, q)
+		if err != nil {
+			return n, err
+		}
+		for _, o := range p.Orders {
+			fields, err := exportFields(o, columns)
+			if err != nil {
+				return n, err
+			}
+			if err := writeOrder(bw, cw, opts.Format, columns, fields); err != nil {
+				return n, fmt.Errorf("failed to write order %s: %w", o.ID, err)
+			}
+			n++
+		}
+		if p.NextCursor == "" {
+			break
+		}
+		q.Cursor = p.NextCursor
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return n, fmt.Errorf("failed to write orders: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return n, fmt.Errorf("failed to write orders: %w", err)
+	}
+	return n, nil
+}
+
+// Import creates every order read from r, in format, in s keeping their IDs and creation times.
+// Returns the number of orders imported.
+//
+// s must be empty, otherwise an [apperr.CodeConstraint] error is returned. Orders imported before
+// an error are left in s.
+func Import(ctx context.Context, r io.Reader, s Store, format Format) (int, error) {
+	if err := validFormat(format); err != nil {
+		return 0, apperr.NewError(apperr.CodeValidation, err)
+	}
+	p, err := s.List(ctx, ListQuery{Limit: 1})
+	if err != nil {
+		return 0, err
+	}
+	if len(p.Orders) > 0 {
+		return 0, apperr.NewError(apperr.CodeConstraint, errors.New("orders can only be imported into an empty store"))
+	}
+	next := readJSONL(r)
+	if format == FormatCSV {
+		next = readCSV(r)
+	}
+	n := 0
+	for {
+		line, o, err := next()
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err == nil {
+			err = validImport(o)
+		}
+		if err == nil {
+			_, err = s.Create(ctx, o)
+		}
+		if err != nil {
+			return n, fmt.Errorf("line %d: %w", line, err)
+		}
+		n++
+	}
+}
+
+func validFormat(f Format) error {
+	if f != FormatJSONL && f != FormatCSV {
+		return fmt.Errorf("unknown format %q", f)
+	}
+	return nil
+}
+
+// validImport checks o has everything a store won't fill in itself, a store would otherwise give
+// it a new ID and creation time.
+func validImport(o Order) error {
+	ve := []error{}
+	if o.ID == "" {
+		ve = append(ve, errors.New("id is required"))
+	}
+	if o.CreatedAt.IsZero() {
+		ve = append(ve, errors.New("createdAt is required"))
+	}
+	if len(ve) > 0 {
+		return apperr.NewError(apperr.CodeValidation, errors.Join(ve...))
+	}
+	return nil
+}
+
+// exportFields returns the JSON encoding of each of columns of o, nil for empty fields.
+func exportFields(o Order, columns []string) ([]json.RawMessage, error) {
+	b, err := json.Marshal(o)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode order %s: %w", o.ID, err)
+	}
+	all := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, fmt.Errorf("failed to encode order %s: %w", o.ID, err)
+	}
+	fields := make([]json.RawMessage, len(columns))
+	for i, v := range columns {
+		fields[i] = all[v]
+	}
+	return fields, nil
+}
+
+func writeOrder(w io.Writer, cw *csv.Writer, format Format, columns []string, fields []json.RawMessage) error {
+	if format == FormatCSV {
+		row := make([]string, len(fields))
+		for i, v := range fields {
+			if v == nil {
+				continue
+			}
+			row[i] = string(v)
+			if stringColumns[columns[i]] {
+				if err := json.Unmarshal(v, &row[i]); err != nil {
+					return err
+				}
+			}
+		}
+		return cw.Write(row)
+	}
+	// build the object by hand to keep columns in the order requested
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, v := range fields {
+		if v == nil {
+			continue
+		}
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Quote(columns[i]))
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteString("}\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// readJSONL returns a function reading the next order and its line number from r.
+func readJSONL(r io.Reader) func() (int, Order, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, maxRecordSize)
+	line := 0
+	return func() (int, Order, error) {
+		for s.Scan() {
+			line++
+			if len(bytes.TrimSpace(s.Bytes())) == 0 {
+				continue
+			}
+			var o Order
+			d := json.NewDecoder(bytes.NewReader(s.Bytes()))
+			d.DisallowUnknownFields()
+			if err := d.Decode(&o); err != nil {
+				return line, Order{}, apperr.NewError(apperr.CodeValidation, fmt.Errorf("invalid order: %w", err))
+			}
+			return line, o, nil
+		}
+		if err := s.Err(); err != nil {
+			return line, Order{}, fmt.Errorf("failed to read orders: %w", err)
+		}
+		return line, Order{}, io.EOF
+	}
+}
+
+// readCSV returns a function reading the next order and its line number from r.
+func readCSV(r io.Reader) func() (int, Order, error) {
+	cr := csv.NewReader(r)
+	var header []string
+	return func() (int, Order, error) {
+		if header == nil {
+			var err error
+			if header, err = cr.Read(); err != nil {
+				if errors.Is(err, io.EOF) {
+					return 0, Order{}, err
+				}
+				return 1, Order{}, fmt.Errorf("failed to read header: %w", err)
+			}
+			for _, v := range header {
+				if !slices.Contains(Columns, v) {
+					return 1, Order{}, apperr.NewError(apperr.CodeValidation, fmt.Errorf("unknown column %q", v))
+				}
+			}
+		}
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return 0, Order{}, err
+		}
+		line, _ := cr.FieldPos(0)
+		if err != nil {
+			return line, Order{}, fmt.Errorf("failed to read orders: %w", err)
+		}
+		// rebuild the JSON encoding of the order from its fields
+		var b bytes.Buffer
+		b.WriteByte('{')
+		for i, v := range row {
+			if v == "" {
+				continue
+			}
+			if b.Len() > 1 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strconv.Quote(header[i]))
+			b.WriteByte(':')
+			if stringColumns[header[i]] {
+				q, err := json.Marshal(v)
+				if err != nil {
+					return line, Order{}, err
+				}
+				v = string(q)
+			}
+			b.WriteString(v)
+		}
+		b.WriteByte('}')
+		var o Order
+		if err := json.Unmarshal(b.Bytes(), &o); err != nil {
+			return line, Order{}, apperr.NewError(apperr.CodeValidation, fmt.Errorf("invalid order: %w", err))
+		}
+		return line, o, nil
+	}
+}
@@ -0,0 +1,211 @@
+package orders
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/products"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 3, 1, 9, 30, 0, 123456789, time.UTC)
+	// seed fills a new store with n fully populated orders created an hour apart.
+	seed := func(t *testing.T, n int) (Store, []Order) {
+		t.Helper()
+		s := NewMem()
+		created := []Order{}
+		for i := range n {
+			at := start.Add(time.Duration(i) * time.Hour)
+			o, err := s.Create(t.Context(), Order{
+				ID:         fmt.Sprintf("order-%d", i),
+				Items:      []OrderItem{{ProductID: "1", Quantity: 2}},
+				Products:   []products.Product{{ID: "1", Name: "Waffle, \"Belgian\"", Price: aud(650), Category: "Waffle"}},
+				Status:     StatusCancelled,
+				CreatedAt:  at,
+				CreatedBy:  "client",
+				CustomerID: "cust-1",
+				LocationID: "city",
+				Totals: Totals{
+					Lines:    []LinePrice{{ProductID: "1", Quantity: 2, UnitPrice: aud(650), Total: aud(1300)}},
+					Subtotal: aud(1300),
+					Discount: aud(130),
+					Total:    aud(1170),
+				},
+				Coupon:       &CouponResult{Code: "HAPPYHRS", Applied: true},
+				Cancellation: &Cancellation{Reason: CancelCustomerRequest, Note: "changed\nmind", At: at.Add(time.Minute), By: "client"},
+				History:      []StatusChange{{From: StatusPlaced, To: StatusCancelled, At: at.Add(time.Minute), Actor: "client"}},
+			})
+			require.NoError(t, err)
+			created = append(created, o)
+		}
+		return s, created
+	}
+	// all lists every order in s.
+	all := func(t *testing.T, s Store) []Order {
+		t.Helper()
+		p, err := s.List(t.Context(), ListQuery{Limit: MaxListLimit})
+		require.NoError(t, err)
+		return p.Orders
+	}
+
+	for _, format := range []Format{FormatJSONL, FormatCSV} {
+		t.Run(fmt.Sprintf("%s round trip is lossless", format), func(t *testing.T) {
+			t.Parallel()
+			// more than a single page of orders
+			src, want := seed(t, MaxListLimit+5)
+
+			var exported bytes.Buffer
+			n, err := Export(t.Context(), &exported, src, ExportOptions{Format: format})
+			require.NoError(t, err)
+			assert.Equal(t, len(want), n)
+
+			dst := NewMem()
+			n, err = Import(t.Context(), bytes.NewReader(exported.Bytes()), dst, format)
+			require.NoError(t, err)
+			assert.Equal(t, len(want), n)
+			got := []Order{}
+			for _, v := range want {
+				o, err := dst.Get(t.Context(), v.ID)
+				require.NoError(t, err)
+				got = append(got, o)
+			}
+			assert.Equal(t, want, got)
+
+			var again bytes.Buffer
+			_, err = Export(t.Context(), &again, dst, ExportOptions{Format: format})
+			require.NoError(t, err)
+			assert.Equal(t, exported.String(), again.String())
+		})
+	}
+
+	t.Run("filters by created range", func(t *testing.T) {
+		t.Parallel()
+		src, created := seed(t, 4)
+		var b bytes.Buffer
+		n, err := Export(t.Context(), &b, src, ExportOptions{Format: FormatJSONL, CreatedFrom: created[1].CreatedAt, CreatedTo: created[3].CreatedAt})
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		dst := NewMem()
+		_, err = Import(t.Context(), &b, dst, FormatJSONL)
+		require.NoError(t, err)
+		assert.Equal(t, created[1:3], all(t, dst))
+	})
+
+	t.Run("selects columns", func(t *testing.T) {
+		t.Parallel()
+		src, _ := seed(t, 2)
+
+		var b bytes.Buffer
+		_, err := Export(t.Context(), &b, src, ExportOptions{Format: FormatCSV, Columns: []string{"total", "id", "items"}})
+		require.NoError(t, err)
+		assert.Equal(t, "total,id,items\n"+
+			`11.7,order-0,"[{""productId"":""1"",""quantity"":2}]"`+"\n"+
+			`11.7,order-1,"[{""productId"":""1"",""quantity"":2}]"`+"\n", b.String())
+
+		b.Reset()
+		_, err = Export(t.Context(), &b, src, ExportOptions{Format: FormatJSONL, Columns: []string{"total", "id"}})
+		require.NoError(t, err)
+		assert.Equal(t, `{"total":11.7,"id":"order-0"}`+"\n"+`{"total":11.7,"id":"order-1"}`+"\n", b.String())
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		t.Parallel()
+		_, err := Export(t.Context(), &bytes.Buffer{}, NewMem(), ExportOptions{
+			Format:      "xml",
+			Columns:     []string{"id", "id", "price"},
+			CreatedFrom: start,
+			CreatedTo:   start,
+		})
+		ae, ok := err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, apperr.CodeValidation, ae.Code)
+		assert.ErrorContains(t, err, `unknown format "xml"`)
+		assert.ErrorContains(t, err, `column "id" is duplicated`)
+		assert.ErrorContains(t, err, `unknown column "price"`)
+		assert.ErrorContains(t, err, "createdFrom must be before createdTo")
+	})
+
+	t.Run("every field has a column", func(t *testing.T) {
+		t.Parallel()
+		_, created := seed(t, 1)
+		fields, err := exportFields(created[0], Columns)
+		require.NoError(t, err)
+		for i, v := range fields {
+			assert.NotNil(t, v, "column %s", Columns[i])
+		}
+		var b bytes.Buffer
+		require.NoError(t, writeOrder(&b, nil, FormatJSONL, Columns, fields))
+		assert.JSONEq(t, mustJSON(t, created[0]), b.String(), "columns must cover every field of an order")
+	})
+}
+
+func TestImport(t *testing.T) {
+	t.Parallel()
+
+	t.Run("store must be empty", func(t *testing.T) {
+		t.Parallel()
+		s := NewMem()
+		_, err := s.Create(t.Context(), Order{Status: StatusPlaced})
+		require.NoError(t, err)
+
+		_, err = Import(t.Context(), strings.NewReader(""), s, FormatJSONL)
+		ae, ok := err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, apperr.CodeConstraint, ae.Code)
+	})
+
+	t.Run("id and createdAt required", func(t *testing.T) {
+		t.Parallel()
+		in := "id,status\n1,placed\n,placed\n"
+		n, err := Import(t.Context(), strings.NewReader(in), NewMem(), FormatCSV)
+		assert.Equal(t, 0, n)
+		assert.ErrorContains(t, err, "line 2:")
+		assert.ErrorContains(t, err, "createdAt is required")
+	})
+
+	t.Run("partial columns", func(t *testing.T) {
+		t.Parallel()
+		s := NewMem()
+		in := "id,createdAt,status\n1,2025-03-01T09:30:00Z,placed\n"
+		n, err := Import(t.Context(), strings.NewReader(in), s, FormatCSV)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		o, err := s.Get(t.Context(), "1")
+		require.NoError(t, err)
+		assert.Equal(t, Order{ID: "1", CreatedAt: time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC), Status: StatusPlaced}, o)
+	})
+
+	t.Run("duplicate id", func(t *testing.T) {
+		t.Parallel()
+		in := `{"id":"1","createdAt":"2025-03-01T09:30:00Z"}` + "\n\n" + `{"id":"1","createdAt":"2025-03-01T09:30:00Z"}` + "\n"
+		n, err := Import(t.Context(), strings.NewReader(in), NewMem(), FormatJSONL)
+		assert.Equal(t, 1, n)
+		assert.ErrorContains(t, err, "line 3: constraint: order 1 already exists")
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		t.Parallel()
+		_, err := Import(t.Context(), strings.NewReader(`{"id":"1","price":1}`), NewMem(), FormatJSONL)
+		assert.ErrorContains(t, err, `line 1: validation: invalid order: json: unknown field "price"`)
+
+		_, err = Import(t.Context(), strings.NewReader("id,price\n"), NewMem(), FormatCSV)
+		assert.ErrorContains(t, err, `line 1: validation: unknown column "price"`)
+	})
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(b)
+}
@@ -0,0 +1,469 @@
+package orders
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/txn"
+)
+
+const (
+	// DefaultSnapshotEvery is the number of journal records written before the journal is
+	// compacted into a snapshot.
+	DefaultSnapshotEvery = 1000
+
+	journalName  = "journal"
+	snapshotName = "snapshot.json"
+	lockName     = "lock"
+	// headerSize is the size of a journal record header, the length of the payload and its CRC-32
+	// checksum followed by the CRC-32 checksum of the two.
+	headerSize = 12
+	// readAttempts is the number of times ReadFile reads a store that's being compacted.
+	readAttempts = 3
+	// maxRecordSize guards against allocating huge buffers for a corrupt length.
+	maxRecordSize = 64 << 20
+)
+
+var _ Store = &File{}
+
+// File is a [Store] that keeps orders durable across restarts using only the local filesystem.
+//
+// Every write is appended to a journal and fsynced before it is acknowledged. On open the latest
+// snapshot is loaded and the journal replayed on top of it. Once the journal has grown by
+// snapshotEvery records it's compacted by writing a new snapshot and emptying the journal.
+//
+// Each journal record holds the full state of a single order, or a tombstone removing one, so
+// replaying a record more than once is harmless, which keeps a crash part way through compaction
+// safe.
+type File struct {
+	// mem serves reads, writes hold mem.mu until they're on disk.
+	mem    Mem
+	dir    string
+	logger *slog.Logger
+	// lock holds the lock on dir until the File is closed.
+	lock *os.File
+
+	journal *os.File
+	// size of the journal up to the end of the last complete record.
+	size          int64
+	records       int
+	snapshotEvery int
+}
+
+// OpenFile opens the orders stored in dir, creating dir if it doesn't exist. A snapshotEvery of
+// zero or less uses [DefaultSnapshotEvery]. Compactions that fail are logged to logger.
+//
+// A torn record at the end of the journal, left by a crash part way through a write, is truncated.
+// Any other corruption is returned as an error rather than risk losing orders.
+//
+// dir is locked until the File is closed so a second OpenFile, from this or another process, fails
+// rather than writing to the same journal. Use [ReadFile] to read a store that's in use.
+func OpenFile(dir string, snapshotEvery int, logger *slog.Logger) (*File, error) {
+	if snapshotEvery <= 0 {
+		snapshotEvery = DefaultSnapshotEvery
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create orders dir: %w", err)
+	}
+	lock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := readSnapshot(filepath.Join(dir, snapshotName))
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	journal, err := os.OpenFile(filepath.Join(dir, journalName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("failed to open orders journal: %w", err)
+	}
+	records, size, err := replay(journal, data, true)
+	if err != nil {
+		journal.Close()
+		lock.Close()
+		return nil, err
+	}
+	mu := sync.RWMutex{}
+	return &File{
+		mem:           Mem{data: data, mu: &mu},
+		dir:           dir,
+		logger:        logger,
+		lock:          lock,
+		journal:       journal,
+		size:          size,
+		records:       records,
+		snapshotEvery: snapshotEvery,
+	}, nil
+}
+
+// ReadFile reads the orders stored in dir into a [Mem] store without changing anything on disk, so
+// it's safe to use while a server has dir open with [OpenFile]. A torn record at the end of the
+// journal, such as one still being written, is skipped. Changes made to the returned store aren't
+// saved.
+func ReadFile(dir string) (Mem, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return Mem{}, fmt.Errorf("failed to read orders dir: %w", err)
+	}
+	// A compaction between reading the snapshot and the journal would leave orders in neither, so
+	// read again if the snapshot was replaced part way through.
+	for range readAttempts {
+		before, err := statSnapshot(dir)
+		if err != nil {
+			return Mem{}, err
+		}
+		data, err := readFile(dir)
+		if err != nil {
+			return Mem{}, err
+		}
+		after, err := statSnapshot(dir)
+		if err != nil {
+			return Mem{}, err
+		}
+		if sameFile(before, after) {
+			mu := sync.RWMutex{}
+			return Mem{data: data, mu: &mu}, nil
+		}
+	}
+	return Mem{}, fmt.Errorf("orders dir %s kept changing while being read", dir)
+}
+
+// readFile reads the snapshot and journal in dir without repairing either.
+func readFile(dir string) (map[string]Order, error) {
+	data, err := readSnapshot(filepath.Join(dir, snapshotName))
+	if err != nil {
+		return nil, err
+	}
+	journal, err := os.Open(filepath.Join(dir, journalName))
+	if errors.Is(err, os.ErrNotExist) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open orders journal: %w", err)
+	}
+	defer journal.Close()
+	if _, _, err := replay(journal, data, false); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// statSnapshot returns the snapshot in dir, nil if there isn't one yet.
+func statSnapshot(dir string) (os.FileInfo, error) {
+	info, err := os.Stat(filepath.Join(dir, snapshotName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat orders snapshot: %w", err)
+	}
+	return info, nil
+}
+
+// sameFile reports whether a and b, either of which may be nil, are the same snapshot.
+func sameFile(a, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return os.SameFile(a, b)
+}
+
+// Create implements [Store.Create].
+func (f *File) Create(ctx context.Context, o Order) (Order, error) {
+	if err := ctx.Err(); err != nil {
+		return Order{}, err
+	}
+	o, err := withDefaults(o)
+	if err != nil {
+		return Order{}, err
+	}
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	if _, has := f.mem.data[o.ID]; has {
+		return Order{}, apperr.NewError(apperr.CodeConstraint, fmt.Errorf("order %s already exists", o.ID))
+	}
+	if err := f.write(record{Order: o}); err != nil {
+		return Order{}, err
+	}
+	txn.OnRollback(ctx, func(context.Context) error {
+		f.mem.mu.Lock()
+		defer f.mem.mu.Unlock()
+		return f.write(record{Order: Order{ID: o.ID}, Deleted: true})
+	})
+	return o, nil
+}
+
+// Get implements [Store.Get].
+func (f *File) Get(ctx context.Context, id string) (Order, error) {
+	return f.mem.Get(ctx, id)
+}
+
+// List implements [Store.List].
+func (f *File) List(ctx context.Context, q ListQuery) (Page, error) {
+	return f.mem.List(ctx, q)
+}
+
+// Update implements [Store.Update].
+func (f *File) Update(ctx context.Context, id string, fn func(Order) (Order, error)) (Order, error) {
+	if err := ctx.Err(); err != nil {
+		return Order{}, err
+	}
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	prev, has := f.mem.data[id]
+	if !has {
+		return Order{}, apperr.NewError(apperr.CodeNotFound, fmt.Errorf("order %s not found", id))
+	}
+	o, err := fn(prev)
+	if err != nil {
+		return Order{}, err
+	}
+	// the identity and creation time of an order never change
+	o.ID, o.CreatedAt = prev.ID, prev.CreatedAt
+	if err := f.write(record{Order: o}); err != nil {
+		return Order{}, err
+	}
+	txn.OnRollback(ctx, func(context.Context) error {
+		f.mem.mu.Lock()
+		defer f.mem.mu.Unlock()
+		return f.write(record{Order: prev})
+	})
+	return o, nil
+}
+
+// Compact writes every order to a new snapshot and empties the journal.
+func (f *File) Compact() error {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	return f.compact()
+}
+
+// Close compacts the journal and closes the store, it must not be used afterwards.
+func (f *File) Close() error {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	err := f.compact()
+	return errors.Join(err, f.journal.Close(), f.lock.Close())
+}
+
+// record is a single journal record.
+type record struct {
+	Order
+	// Deleted marks a tombstone removing the order, written when creating it is rolled back.
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// apply applies r to data.
+func (r record) apply(data map[string]Order) {
+	if r.Deleted {
+		delete(data, r.ID)
+		return
+	}
+	data[r.ID] = r.Order
+}
+
+// write durably records r and then makes it visible to readers. f.mem.mu must be held.
+func (f *File) write(r record) error {
+	o := r.Order
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode order %s: %w", o.ID, err)
+	}
+	rec := make([]byte, headerSize, headerSize+len(payload))
+	binary.BigEndian.PutUint32(rec[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(rec[4:8], crc32.ChecksumIEEE(payload))
+	binary.BigEndian.PutUint32(rec[8:12], crc32.ChecksumIEEE(rec[0:8]))
+	rec = append(rec, payload...)
+	if _, err := f.journal.Write(rec); err != nil {
+		return errors.Join(fmt.Errorf("failed to write order %s to journal: %w", o.ID, err), truncate(f.journal, f.size))
+	}
+	if err := f.journal.Sync(); err != nil {
+		return errors.Join(fmt.Errorf("failed to sync orders journal: %w", err), truncate(f.journal, f.size))
+	}
+	r.apply(f.mem.data)
+	f.size += int64(len(rec))
+	f.records++
+	if f.records >= f.snapshotEvery {
+		// the order is already durable so a failed compaction doesn't fail the write, it's tried
+		// again on the next write and on Close
+		if err := f.compact(); err != nil {
+			f.logger.Error("failed to compact orders journal", slog.String("dir", f.dir), slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+// compact writes a snapshot of f.mem.data and then empties the journal. f.mem.mu must be held.
+func (f *File) compact() error {
+	if f.records == 0 {
+		return nil
+	}
+	snapshot := make([]Order, 0, len(f.mem.data))
+	for _, v := range f.mem.data {
+		snapshot = append(snapshot, v)
+	}
+	slices.SortFunc(snapshot, func(a, b Order) int { return cmp.Compare(a.ID, b.ID) })
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode orders snapshot: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(f.dir, snapshotName), b); err != nil {
+		return err
+	}
+	// a crash before this point replays the journal on top of the new snapshot, which is harmless
+	if err := truncate(f.journal, 0); err != nil {
+		return err
+	}
+	f.size, f.records = 0, 0
+	return nil
+}
+
+// readSnapshot loads the orders in the snapshot at path, a missing snapshot holds no orders.
+func readSnapshot(path string) (map[string]Order, error) {
+	data := map[string]Order{}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read orders snapshot: %w", err)
+	}
+	var snapshot []Order
+	if err := json.Unmarshal(b, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode orders snapshot: %w", err)
+	}
+	for _, v := range snapshot {
+		data[v.ID] = v
+	}
+	return data, nil
+}
+
+// replay applies every record in journal to data returning the number of records read and the
+// size of the journal. When repair is set a torn final record is truncated and journal is left
+// positioned for appending, otherwise it's skipped and journal is left as it is.
+func replay(journal *os.File, data map[string]Order, repair bool) (int, int64, error) {
+	info, err := journal.Stat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to stat orders journal: %w", err)
+	}
+	size := info.Size()
+	r := bufio.NewReader(journal)
+	var (
+		offset  int64
+		records int
+		header  [headerSize]byte
+	)
+	torn := func() (int, int64, error) {
+		if !repair {
+			return records, offset, nil
+		}
+		return records, offset, truncate(journal, offset)
+	}
+	for offset < size {
+		if size-offset < headerSize {
+			return torn()
+		}
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return 0, 0, fmt.Errorf("failed to read orders journal: %w", err)
+		}
+		// the length in a bad header can't be trusted so there's no telling whether the record is
+		// the last in the journal
+		if crc32.ChecksumIEEE(header[0:8]) != binary.BigEndian.Uint32(header[8:12]) {
+			return 0, 0, fmt.Errorf("orders journal corrupt at offset %d: header checksum mismatch", offset)
+		}
+		n := int64(binary.BigEndian.Uint32(header[0:4]))
+		if n > maxRecordSize {
+			return 0, 0, fmt.Errorf("orders journal corrupt at offset %d: record too large", offset)
+		}
+		end := offset + headerSize + n
+		if end > size {
+			return torn()
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return 0, 0, fmt.Errorf("failed to read orders journal: %w", err)
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+			if end == size {
+				return torn()
+			}
+			return 0, 0, fmt.Errorf("orders journal corrupt at offset %d: checksum mismatch", offset)
+		}
+		var r record
+		if err := json.Unmarshal(payload, &r); err != nil {
+			return 0, 0, fmt.Errorf("orders journal corrupt at offset %d: %w", offset, err)
+		}
+		r.apply(data)
+		records++
+		offset = end
+	}
+	if !repair {
+		return records, offset, nil
+	}
+	if _, err := journal.Seek(offset, io.SeekStart); err != nil {
+		return 0, 0, fmt.Errorf("failed to seek orders journal: %w", err)
+	}
+	return records, offset, nil
+}
+
+// truncate drops everything from offset onwards from journal, such as a torn record.
+func truncate(journal *os.File, offset int64) error {
+	if err := journal.Truncate(offset); err != nil {
+		return fmt.Errorf("failed to truncate orders journal: %w", err)
+	}
+	if err := journal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync orders journal: %w", err)
+	}
+	if _, err := journal.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek orders journal: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces the file at path with b so that a crash leaves either the old or the
+// new contents in place.
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	// sync the directory so the rename itself survives a crash
+	d, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filepath.Dir(path), err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", filepath.Dir(path), err)
+	}
+	return nil
+}
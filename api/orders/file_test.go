@@ -0,0 +1,304 @@
+package orders
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/products"
+	"github.com/matgreaves/kart-challenge/api/txn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile(t *testing.T) {
+	t.Parallel()
+
+	// testOrder returns a fully populated order so a round trip through the disk covers every field.
+	testOrder := func() Order {
+		return Order{
+			Items:     testReq().Items,
+			Products:  []products.Product{{ID: "1", Name: "Waffle", Price: aud(650), Category: "Waffle"}},
+			Status:    StatusPlaced,
+			CreatedBy: "a",
+			Totals: Totals{
+				Lines:    []LinePrice{{ProductID: "1", Quantity: 1, UnitPrice: aud(650), Total: aud(650)}},
+				Subtotal: aud(650),
+				Discount: aud(65),
+				Total:    aud(585),
+			},
+			Coupon: &CouponResult{Code: "HAPPYHRS", Applied: true},
+		}
+	}
+
+	// open opens the store in dir failing the test if it can't.
+	open := func(t *testing.T, dir string, snapshotEvery int) *File {
+		t.Helper()
+		f, err := OpenFile(dir, snapshotEvery, slog.New(slog.DiscardHandler))
+		require.NoError(t, err)
+		return f
+	}
+
+	// crash closes f without compacting, as if the process died, everything acknowledged is in
+	// the journal.
+	crash := func(t *testing.T, f *File) {
+		t.Helper()
+		require.NoError(t, f.journal.Close())
+		require.NoError(t, f.lock.Close())
+	}
+
+	t.Run("orders survive reopening", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		f := open(t, dir, 0)
+		created, err := f.Create(t.Context(), testOrder())
+		require.NoError(t, err)
+		updated, err := f.Update(t.Context(), created.ID, func(o Order) (Order, error) {
+			return transition(o, StatusAccepted, "staff")
+		})
+		require.NoError(t, err)
+		other, err := f.Create(t.Context(), testOrder())
+		require.NoError(t, err)
+		crash(t, f)
+
+		f = open(t, dir, 0)
+		defer f.Close()
+		got, err := f.Get(t.Context(), created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, updated, got)
+		got, err = f.Get(t.Context(), other.ID)
+		require.NoError(t, err)
+		assert.Equal(t, other, got)
+
+		_, err = f.Create(t.Context(), Order{ID: other.ID})
+		var ae apperr.Error
+		require.ErrorAs(t, err, &ae, "duplicate id rejected after reopening")
+		assert.Equal(t, apperr.CodeConstraint, ae.Code)
+	})
+
+	t.Run("rolled back create survives reopening", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		f := open(t, dir, 0)
+		ctx, tx := txn.Begin(t.Context())
+		o, err := f.Create(ctx, testOrder())
+		require.NoError(t, err)
+		require.NoError(t, tx.Rollback(ctx))
+		_, err = f.Get(t.Context(), o.ID)
+		assert.Error(t, err)
+		crash(t, f)
+
+		f = open(t, dir, 0)
+		defer f.Close()
+		_, err = f.Get(t.Context(), o.ID)
+		ae, ok := err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, apperr.CodeNotFound, ae.Code)
+	})
+
+	t.Run("dir locked while open", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		f := open(t, dir, 0)
+		_, err := OpenFile(dir, 0, slog.New(slog.DiscardHandler))
+		assert.ErrorContains(t, err, "is already open")
+
+		require.NoError(t, f.Close())
+		f = open(t, dir, 0)
+		assert.NoError(t, f.Close())
+	})
+
+	t.Run("read while open", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		f := open(t, dir, 2)
+		defer f.Close()
+		var created []Order
+		for range 3 {
+			o, err := f.Create(t.Context(), testOrder())
+			require.NoError(t, err)
+			created = append(created, o)
+		}
+		// a record part way through being written
+		path := filepath.Join(dir, journalName)
+		journal, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		require.NoError(t, err)
+		_, err = journal.Write([]byte{0, 0, 1})
+		require.NoError(t, err)
+		require.NoError(t, journal.Close())
+		before, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		m, err := ReadFile(dir)
+		require.NoError(t, err)
+		for _, o := range created {
+			_, err := m.Get(t.Context(), o.ID)
+			assert.NoError(t, err)
+		}
+		after, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, before, after, "journal changed by reading")
+	})
+
+	t.Run("journal compacted into snapshot", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		f := open(t, dir, 2)
+		ids := []string{}
+		for range 5 {
+			o, err := f.Create(t.Context(), testOrder())
+			require.NoError(t, err)
+			ids = append(ids, o.ID)
+		}
+		assert.Equal(t, 1, f.records, "journal emptied every 2 records")
+		require.NoError(t, f.Close())
+
+		info, err := os.Stat(filepath.Join(dir, journalName))
+		require.NoError(t, err)
+		assert.Zero(t, info.Size(), "close compacts what's left")
+
+		f = open(t, dir, 2)
+		defer f.Close()
+		p, err := f.List(t.Context(), ListQuery{Limit: MaxListLimit})
+		require.NoError(t, err)
+		assert.Len(t, p.Orders, len(ids))
+	})
+
+	t.Run("failed compaction logged", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		logs := &bytes.Buffer{}
+		f, err := OpenFile(dir, 1, slog.New(slog.NewJSONHandler(logs, nil)))
+		require.NoError(t, err)
+		// a snapshot can't replace a directory that isn't empty
+		snapshot := filepath.Join(dir, snapshotName)
+		require.NoError(t, os.MkdirAll(filepath.Join(snapshot, "blocked"), 0o755))
+
+		o, err := f.Create(t.Context(), testOrder())
+		require.NoError(t, err, "the order is durable so the write succeeds")
+		assert.Contains(t, logs.String(), "failed to compact orders journal")
+		assert.Equal(t, 1, f.records, "journal kept")
+
+		require.NoError(t, os.RemoveAll(snapshot))
+		require.NoError(t, f.Close())
+		f = open(t, dir, 1)
+		defer f.Close()
+		_, err = f.Get(t.Context(), o.ID)
+		assert.NoError(t, err)
+	})
+
+	// header returns a record header for a payload of n bytes with the checksum sum.
+	header := func(n, sum uint32) []byte {
+		b := make([]byte, headerSize)
+		binary.BigEndian.PutUint32(b[0:4], n)
+		binary.BigEndian.PutUint32(b[4:8], sum)
+		binary.BigEndian.PutUint32(b[8:12], crc32.ChecksumIEEE(b[0:8]))
+		return b
+	}
+
+	t.Run("torn final record truncated", func(t *testing.T) {
+		t.Parallel()
+		for name, tear := range map[string]func(b []byte) []byte{
+			"partial header":  func(b []byte) []byte { return append(b, 0, 0, 1) },
+			"partial payload": func(b []byte) []byte { return append(append(b, header(10, 1234)...), '{') },
+			"bad checksum":    func(b []byte) []byte { return append(append(b, header(2, 1234)...), '{', '}') },
+		} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				dir := t.TempDir()
+				f := open(t, dir, 0)
+				o, err := f.Create(t.Context(), testOrder())
+				require.NoError(t, err)
+				crash(t, f)
+
+				path := filepath.Join(dir, journalName)
+				b, err := os.ReadFile(path)
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(path, tear(b), 0o644))
+
+				f = open(t, dir, 0)
+				_, err = f.Get(t.Context(), o.ID)
+				require.NoError(t, err)
+				info, err := os.Stat(path)
+				require.NoError(t, err)
+				assert.Equal(t, int64(len(b)), info.Size())
+
+				// writes after the truncation are readable
+				next, err := f.Create(t.Context(), testOrder())
+				require.NoError(t, err)
+				crash(t, f)
+				f = open(t, dir, 0)
+				defer f.Close()
+				_, err = f.Get(t.Context(), next.ID)
+				assert.NoError(t, err)
+			})
+		}
+	})
+
+	t.Run("corruption before the final record", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		f := open(t, dir, 0)
+		for range 2 {
+			_, err := f.Create(t.Context(), testOrder())
+			require.NoError(t, err)
+		}
+		crash(t, f)
+
+		path := filepath.Join(dir, journalName)
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		b[headerSize+1] ^= 0xff
+		require.NoError(t, os.WriteFile(path, b, 0o644))
+
+		_, err = OpenFile(dir, 0, slog.New(slog.DiscardHandler))
+		assert.ErrorContains(t, err, "orders journal corrupt at offset 0: checksum mismatch")
+	})
+
+	t.Run("corrupt final header", func(t *testing.T) {
+		t.Parallel()
+		for name, tc := range map[string]struct {
+			corrupt func(b []byte) []byte
+			want    string
+		}{
+			"bad header checksum": {
+				corrupt: func(b []byte) []byte {
+					h := header(2, crc32.ChecksumIEEE([]byte("{}")))
+					h[0] ^= 0xff
+					return append(append(b, h...), '{', '}')
+				},
+				want: "header checksum mismatch",
+			},
+			"record too large": {
+				corrupt: func(b []byte) []byte { return append(b, header(maxRecordSize+1, 0)...) },
+				want:    "record too large",
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				dir := t.TempDir()
+				f := open(t, dir, 0)
+				_, err := f.Create(t.Context(), testOrder())
+				require.NoError(t, err)
+				crash(t, f)
+
+				path := filepath.Join(dir, journalName)
+				b, err := os.ReadFile(path)
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(path, tc.corrupt(b), 0o644))
+
+				_, err = OpenFile(dir, 0, slog.New(slog.DiscardHandler))
+				assert.ErrorContains(t, err, fmt.Sprintf("orders journal corrupt at offset %d: %s", len(b), tc.want))
+				info, err := os.Stat(path)
+				require.NoError(t, err)
+				assert.Greater(t, info.Size(), int64(len(b)), "not truncated")
+			})
+		}
+	})
+}
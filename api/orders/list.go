@@ -0,0 +1,137 @@
+package orders
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+)
+
+// MaxListLimit is the largest number of orders a single [Store.List] call will return.
+const MaxListLimit = 100
+
+// ListQuery filters and paginates a call to [Store.List].
+//
+// Orders are always listed oldest first, ties broken by ID, so a cursor remains stable while new
+// orders are being created.
+type ListQuery struct {
+	// CreatedBy, when set, restricts results to orders placed by the given principal.
+	CreatedBy string
+	// CreatedFrom, when non zero, excludes orders created before it.
+	CreatedFrom time.Time
+	// CreatedTo, when non zero, excludes orders created at or after it.
+	CreatedTo time.Time
+	// Statuses, when not empty, restricts results to orders in one of the given statuses.
+	Statuses []Status
+	// Cursor continues on from a previous [Page.NextCursor], empty for the first page.
+	Cursor string
+	// Limit is the maximum number of orders to return, between 1 and [MaxListLimit].
+	Limit int
+}
+
+// Page is a single page of results from [Store.List].
+type Page struct {
+	Orders []Order `json:"orders"`
+	// NextCursor is set when more results may follow and is passed as [ListQuery.Cursor] to
+	// fetch them.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// Validate checks whether q is well formed.
+func (q ListQuery) Validate() error {
+	ve := []error{}
+	if q.Limit < 1 || q.Limit > MaxListLimit {
+		ve = append(ve, fmt.Errorf("limit must be between 1 and %d", MaxListLimit))
+	}
+	if !q.CreatedFrom.IsZero() && !q.CreatedTo.IsZero() && !q.CreatedFrom.Before(q.CreatedTo) {
+		ve = append(ve, errors.New("createdFrom must be before createdTo"))
+	}
+	for _, v := range q.Statuses {
+		if _, has := statuses[v]; !has {
+			ve = append(ve, fmt.Errorf("unknown status %q", v))
+		}
+	}
+	if _, _, err := decodeCursor(q.Cursor); err != nil {
+		ve = append(ve, err)
+	}
+	if len(ve) > 0 {
+		return apperr.NewError(apperr.CodeValidation, errors.Join(ve...))
+	}
+	return nil
+}
+
+// Matches reports whether o satisfies the filters in q, ignoring pagination.
+func (q ListQuery) Matches(o Order) bool {
+	if q.CreatedBy != "" && o.CreatedBy != q.CreatedBy {
+		return false
+	}
+	if !q.CreatedFrom.IsZero() && o.CreatedAt.Before(q.CreatedFrom) {
+		return false
+	}
+	if !q.CreatedTo.IsZero() && !o.CreatedAt.Before(q.CreatedTo) {
+		return false
+	}
+	if len(q.Statuses) == 0 {
+		return true
+	}
+	for _, v := range q.Statuses {
+		if o.Status == v {
+			return true
+		}
+	}
+	return false
+}
+
+// listCompare is the sort order used when listing orders.
+func listCompare(a, b Order) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// encodeCursor returns an opaque cursor pointing just after o.
+func encodeCursor(o Order) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(o.CreatedAt.Format(time.RFC3339Nano) + " " + o.ID))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	if cursor == "" {
+		return time.Time{}, "", nil
+	}
+	invalid := errors.New("cursor is invalid")
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", invalid
+	}
+	ts, id, found := strings.Cut(string(b), " ")
+	if !found {
+		return time.Time{}, "", invalid
+	}
+	at, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, "", invalid
+	}
+	return at, id, nil
+}
+
+// paginate selects the page described by q from sorted, which must only contain orders matching q
+// in list order. q must have already been validated with [ListQuery.Validate].
+func paginate(sorted []Order, q ListQuery) Page {
+	start := 0
+	if q.Cursor != "" {
+		at, id, _ := decodeCursor(q.Cursor)
+		after := Order{CreatedAt: at, ID: id}
+		start = sort.Search(len(sorted), func(i int) bool { return listCompare(sorted[i], after) > 0 })
+	}
+	end := min(start+q.Limit, len(sorted))
+	p := Page{Orders: sorted[start:end]}
+	if end < len(sorted) {
+		p.NextCursor = encodeCursor(sorted[end-1])
+	}
+	return p
+}
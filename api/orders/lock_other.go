@@ -0,0 +1,19 @@
+//go:build !unix
+
+package orders
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockDir opens the lock file in dir. Locking isn't supported on this platform so it's up to the
+// caller not to open dir twice.
+func lockDir(dir string) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(dir, lockName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open orders lock: %w", err)
+	}
+	return f, nil
+}
@@ -0,0 +1,28 @@
+//go:build unix
+
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockDir takes an exclusive lock on dir that's held until the returned file is closed. It fails
+// straight away if dir is already locked rather than waiting.
+func lockDir(dir string) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(dir, lockName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open orders lock: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("orders dir %s is already open", dir)
+		}
+		return nil, fmt.Errorf("failed to lock orders dir: %w", err)
+	}
+	return f, nil
+}
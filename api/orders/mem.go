@@ -3,9 +3,12 @@ package orders
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sync"
+	"time"
 
-	"github.com/google/uuid"
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/txn"
 )
 
 var _ Store = Mem{}
@@ -30,13 +33,99 @@ type Mem struct {
 // Orders products are denormalised and stored alongside the order for better traceability though
 // another possible option would be to fill at read time to allow fixing of product data.
 func (m Mem) Create(ctx context.Context, o Order) (Order, error) {
-	id, err := uuid.NewRandom()
+	if err := ctx.Err(); err != nil {
+		return Order{}, err
+	}
+	o, err := withDefaults(o)
 	if err != nil {
-		return Order{}, fmt.Errorf("orders failed to create uuid: %w", err)
+		return Order{}, err
 	}
-	o.ID = id.String()
 	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, has := m.data[o.ID]; has {
+		return Order{}, apperr.NewError(apperr.CodeConstraint, fmt.Errorf("order %s already exists", o.ID))
+	}
 	m.data[o.ID] = o
-	m.mu.Unlock()
+	txn.OnRollback(ctx, func(context.Context) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.data, o.ID)
+		return nil
+	})
+	return o, nil
+}
+
+// Get implements [Store.Get].
+func (m Mem) Get(ctx context.Context, id string) (Order, error) {
+	if err := ctx.Err(); err != nil {
+		return Order{}, err
+	}
+	m.mu.RLock()
+	o, has := m.data[id]
+	m.mu.RUnlock()
+	if !has {
+		return Order{}, apperr.NewError(apperr.CodeNotFound, fmt.Errorf("order %s not found", id))
+	}
+	return o, nil
+}
+
+// List implements [Store.List].
+func (m Mem) List(ctx context.Context, q ListQuery) (Page, error) {
+	if err := ctx.Err(); err != nil {
+		return Page{}, err
+	}
+	if err := q.Validate(); err != nil {
+		return Page{}, err
+	}
+	matched := []Order{}
+	m.mu.RLock()
+	for _, o := range m.data {
+		if q.Matches(o) {
+			matched = append(matched, o)
+		}
+	}
+	m.mu.RUnlock()
+	slices.SortFunc(matched, listCompare)
+	return paginate(matched, q), nil
+}
+
+// Update implements [Store.Update].
+func (m Mem) Update(ctx context.Context, id string, fn func(Order) (Order, error)) (Order, error) {
+	if err := ctx.Err(); err != nil {
+		return Order{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev, has := m.data[id]
+	if !has {
+		return Order{}, apperr.NewError(apperr.CodeNotFound, fmt.Errorf("order %s not found", id))
+	}
+	o, err := fn(prev)
+	if err != nil {
+		return Order{}, err
+	}
+	// the identity and creation time of an order never change
+	o.ID, o.CreatedAt = prev.ID, prev.CreatedAt
+	m.data[id] = o
+	txn.OnRollback(ctx, func(context.Context) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.data[id] = prev
+		return nil
+	})
+	return o, nil
+}
+
+// withDefaults assigns o an ID and creation time if it doesn't already have them.
+func withDefaults(o Order) (Order, error) {
+	if o.ID == "" {
+		var err error
+		if o.ID, err = NewID(); err != nil {
+			return Order{}, err
+		}
+	}
+	if o.CreatedAt.IsZero() {
+		o.CreatedAt = time.Now().UTC()
+	}
 	return o, nil
 }
@@ -0,0 +1,162 @@
+package orders
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMem_Get(t *testing.T) {
+	t.Parallel()
+
+	t.Run("has order", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem()
+		o, err := m.Create(t.Context(), Order{Items: testReq().Items})
+		require.NoError(t, err)
+
+		got, err := m.Get(t.Context(), o.ID)
+		require.NoError(t, err)
+		assert.Equal(t, o, got)
+	})
+
+	t.Run("no order", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewMem().Get(t.Context(), "missing")
+		ae, ok := err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, apperr.CodeNotFound, ae.Code)
+		assert.ErrorContains(t, err, "order missing not found")
+	})
+}
+
+func TestMem_List(t *testing.T) {
+	t.Parallel()
+
+	// seed creates n orders split between two creators returning them in list order.
+	seed := func(t *testing.T, m Store, n int) []Order {
+		t.Helper()
+		created := []Order{}
+		for i := range n {
+			o, err := m.Create(t.Context(), Order{Items: testReq().Items, Status: StatusPlaced, CreatedBy: []string{"a", "b"}[i%2]})
+			require.NoError(t, err)
+			created = append(created, o)
+		}
+		slices.SortFunc(created, listCompare)
+		return created
+	}
+
+	t.Run("pages through all orders", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem()
+		want := seed(t, m, 5)
+
+		got := []Order{}
+		q := ListQuery{Limit: 2}
+		for range 3 {
+			p, err := m.List(t.Context(), q)
+			require.NoError(t, err)
+			got = append(got, p.Orders...)
+			q.Cursor = p.NextCursor
+		}
+		assert.Empty(t, q.Cursor)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("filters by creator", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem()
+		seed(t, m, 5)
+
+		p, err := m.List(t.Context(), ListQuery{CreatedBy: "b", Limit: MaxListLimit})
+		require.NoError(t, err)
+		assert.Len(t, p.Orders, 2)
+		for _, o := range p.Orders {
+			assert.Equal(t, "b", o.CreatedBy)
+		}
+	})
+
+	t.Run("filters by created range", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem()
+		all := seed(t, m, 3)
+
+		p, err := m.List(t.Context(), ListQuery{CreatedFrom: all[1].CreatedAt, CreatedTo: all[2].CreatedAt, Limit: MaxListLimit})
+		require.NoError(t, err)
+		assert.Equal(t, all[1:2], p.Orders)
+	})
+
+	t.Run("filters by status", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem()
+		seed(t, m, 3)
+
+		p, err := m.List(t.Context(), ListQuery{Statuses: []Status{StatusPlaced}, Limit: MaxListLimit})
+		require.NoError(t, err)
+		assert.Len(t, p.Orders, 3)
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewMem().List(t.Context(), ListQuery{Cursor: "!!", Statuses: []Status{"lost"}})
+		ae, ok := err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, apperr.CodeValidation, ae.Code)
+		assert.ErrorContains(t, err, "limit must be between 1 and 100")
+		assert.ErrorContains(t, err, `unknown status "lost"`)
+		assert.ErrorContains(t, err, "cursor is invalid")
+	})
+}
+
+func TestMem_Update(t *testing.T) {
+	t.Parallel()
+
+	t.Run("updates order", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem()
+		o, err := m.Create(t.Context(), Order{Items: testReq().Items, Status: StatusPlaced})
+		require.NoError(t, err)
+
+		got, err := m.Update(t.Context(), o.ID, func(o Order) (Order, error) {
+			o.Status = StatusAccepted
+			o.ID = "changed"
+			return o, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, o.ID, got.ID)
+		assert.Equal(t, StatusAccepted, got.Status)
+
+		stored, err := m.Get(t.Context(), o.ID)
+		require.NoError(t, err)
+		assert.Equal(t, got, stored)
+	})
+
+	t.Run("fn fails", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem()
+		o, err := m.Create(t.Context(), Order{Items: testReq().Items, Status: StatusPlaced})
+		require.NoError(t, err)
+
+		_, err = m.Update(t.Context(), o.ID, func(o Order) (Order, error) {
+			o.Status = StatusAccepted
+			return o, errors.New("nope")
+		})
+		assert.EqualError(t, err, "nope")
+
+		stored, err := m.Get(t.Context(), o.ID)
+		require.NoError(t, err)
+		assert.Equal(t, o, stored)
+	})
+
+	t.Run("no order", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewMem().Update(t.Context(), "missing", func(o Order) (Order, error) { return o, nil })
+		ae, ok := err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, apperr.CodeNotFound, ae.Code)
+	})
+}
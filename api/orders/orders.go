@@ -4,15 +4,34 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/matgreaves/kart-challenge/api/apperr"
 	"github.com/matgreaves/kart-challenge/api/coupons"
+	"github.com/matgreaves/kart-challenge/api/inventory"
+	"github.com/matgreaves/kart-challenge/api/locations"
+	"github.com/matgreaves/kart-challenge/api/money"
 	"github.com/matgreaves/kart-challenge/api/products"
+	"github.com/matgreaves/kart-challenge/api/txn"
 )
 
 // Store is the interface for interacting with order data.
+//
+// Every method returns ctx.Err() once ctx is done without making any change. Implementations are
+// checked with storetest.Orders.
 type Store interface {
-	Create(ctx context.Context, req Order) (Order, error)
+	// Create persists o assigning it an ID if it doesn't already have one. Returns an
+	// [apperr.CodeConstraint] error if an order with the same ID already exists. The order is
+	// removed again if ctx belongs to a [txn.Tx] that is rolled back.
+	Create(ctx context.Context, o Order) (Order, error)
+	Get(ctx context.Context, id string) (Order, error)
+	List(ctx context.Context, q ListQuery) (Page, error)
+	// Update atomically replaces the order with id by the result of fn. If fn returns an error the
+	// order is left unchanged and the error returned. The previous order is put back if ctx belongs
+	// to a [txn.Tx] that is rolled back.
+	Update(ctx context.Context, id string, fn func(Order) (Order, error)) (Order, error)
 }
 
 // Order defines the data model for an order.
@@ -20,9 +39,23 @@ type Order struct {
 	ID       string             `json:"id,omitempty"`
 	Items    []OrderItem        `json:"items,omitempty"`
 	Products []products.Product `json:"products,omitempty"`
-	// The example servers includes this field but I've removed it as it doesn't exist
-	// in the OpenAPI spec.
-	// CouponCode string `json:"couponCode,omitempty"`
+	Status   Status             `json:"status,omitempty"`
+	// CreatedAt is set by the [Store] when the order is first persisted and never changes.
+	CreatedAt time.Time `json:"createdAt"`
+	// CreatedBy identifies the principal that placed the order.
+	CreatedBy string `json:"createdBy,omitempty"`
+	// CustomerID identifies the customer the order was placed for, when known.
+	CustomerID string `json:"customerId,omitempty"`
+	// LocationID identifies the location the order was placed at, empty for the default location.
+	LocationID string `json:"locationId,omitempty"`
+	Totals
+	// Coupon is set when the order was placed with a coupon code. The example server returns
+	// the bare couponCode instead, this also tells the client whether the coupon did anything.
+	Coupon *CouponResult `json:"coupon,omitempty"`
+	// Cancellation is set once the order has been cancelled.
+	Cancellation *Cancellation `json:"cancellation,omitempty"`
+	// History records every change of Status since the order was placed, oldest first.
+	History []StatusChange `json:"history,omitempty"`
 }
 
 // OrderItem is a single product within an [Order].
@@ -34,50 +67,209 @@ type OrderItem struct {
 // OrderReq Place a new order
 type OrderReq struct {
 	// CouponCode Optional promo code applied to the order
-	CouponCode string      `json:"couponCode,omitempty"`
+	CouponCode string `json:"couponCode,omitempty"`
+	// CustomerID Optional ID of the customer the order is for, required by coupons limited per customer
+	CustomerID string `json:"customerId,omitempty"`
+	// LocationID Optional ID of the location the order is placed at, the default location when empty
+	LocationID string      `json:"locationId,omitempty"`
 	Items      []OrderItem `json:"items"`
 }
 
-// Validate checks whether o is well formed.
-func (o *OrderReq) Validate() error {
+// Validate checks whether o is well formed and within the limits of p. Problems with a
+// single item are reported against the index of the item in o.Items.
+func (o *OrderReq) Validate(p Policy) error {
 	ve := []error{}
 	if len(o.Items) == 0 {
 		ve = append(ve, errors.New("at least one item is required"))
 	}
+	if p.MaxLines > 0 && len(o.Items) > p.MaxLines {
+		// no point checking thousands of items we won't accept
+		return fmt.Errorf("order cannot have more than %d items", p.MaxLines)
+	}
+	first := map[string]int{}
+	combined := map[string]int{}
+	units := 0
 	for i, v := range o.Items {
 		if v.ProductID == "" {
 			ve = append(ve, fmt.Errorf("item[%d] productId is required", i))
 		}
-		// NOTE: similar error message as example server, but the example server returns that
-		// error on quantity == 0 and not on < 0. Using logic in the spirit of the error message
-		// rather than the observed behaviour.
-		if v.Quantity < 0 {
+		switch {
+		case v.Quantity < 0 && p.MinQuantity <= 0:
+			// NOTE: similar error message as example server, but the example server returns that
+			// error on quantity == 0 and not on < 0. Using logic in the spirit of the error message
+			// rather than the observed behaviour.
 			ve = append(ve, fmt.Errorf("item[%d] quantity cannot be less than zero", i))
+		case v.Quantity < p.MinQuantity:
+			ve = append(ve, fmt.Errorf("item[%d] quantity must be at least %d", i, p.MinQuantity))
+		case p.MaxQuantity > 0 && v.Quantity > p.MaxQuantity:
+			ve = append(ve, fmt.Errorf("item[%d] quantity must be at most %d", i, p.MaxQuantity))
+		default:
+			if _, seen := first[v.ProductID]; !seen {
+				first[v.ProductID] = i
+			}
+			combined[v.ProductID] += v.Quantity
+			units += v.Quantity
+		}
+	}
+	for id, q := range combined {
+		if p.MaxQuantity > 0 && q > p.MaxQuantity {
+			ve = append(ve, fmt.Errorf("item[%d] quantity must be at most %d once duplicate items are combined", first[id], p.MaxQuantity))
 		}
 	}
+	if p.MaxUnits > 0 && units > p.MaxUnits {
+		ve = append(ve, fmt.Errorf("order cannot have more than %d units in total", p.MaxUnits))
+	}
 	if len(ve) > 0 {
 		return errors.Join(ve...)
 	}
 	return nil
 }
 
-// Create takes an [OrderReq], validates it, and persists it returning the persisted [Order].
-func Create(ctx context.Context, req OrderReq, os Store, ps products.Store, cs coupons.Store) (Order, error) {
-	if err := req.Validate(); err != nil {
+// Normalise returns o with any items for the same product combined into a single item, keeping
+// the position of the first.
+func (o OrderReq) Normalise() OrderReq {
+	items := make([]OrderItem, 0, len(o.Items))
+	index := map[string]int{}
+	for _, v := range o.Items {
+		if i, has := index[v.ProductID]; has {
+			items[i].Quantity += v.Quantity
+			continue
+		}
+		index[v.ProductID] = len(items)
+		items = append(items, v)
+	}
+	o.Items = items
+	return o
+}
+
+// Service places and manages orders.
+type Service struct {
+	Orders Store
+	// Products is the catalogue of the default location, used by orders that don't name a location.
+	Products products.Store
+	// Locations has the catalogue of every other location, when nil orders can only be placed at
+	// the default location.
+	Locations locations.Store
+	Coupons   coupons.Store
+	// Redemptions tracks uses of limited use coupons, when nil every coupon is unlimited.
+	Redemptions coupons.Redemptions
+	// Inventory has stock reserved for every order, when nil stock isn't tracked.
+	Inventory inventory.Store
+	// Policy limits the orders that can be placed, when nil [DefaultPolicy] is used. The zero
+	// Policy enforces no limits.
+	Policy *Policy
+	// Events are published to whenever an order is created or changes status, when nil no
+	// events are published.
+	Events Publisher
+	// Logger records failures that happen after a change is saved so can't fail the request, when
+	// nil [slog.Default] is used.
+	Logger *slog.Logger
+}
+
+// Create takes an [OrderReq] placed by createdBy, validates it, and persists it returning the persisted [Order].
+func (s Service) Create(ctx context.Context, createdBy string, req OrderReq) (Order, error) {
+	if err := req.Validate(s.policy()); err != nil {
 		return Order{}, apperr.NewError(apperr.CodeConstraint, err)
 	}
-	if req.CouponCode != "" && !cs.Has(req.CouponCode) {
-		return Order{}, apperr.NewError(apperr.CodeConstraint, errors.New("invalid couponCode specified"))
+	req = req.Normalise()
+	var coupon coupons.Coupon
+	if req.CouponCode != "" {
+		var err error
+		coupon, err = s.Coupons.Lookup(ctx, req.CouponCode)
+		var ae apperr.Error
+		if errors.As(err, &ae) && ae.Code == apperr.CodeNotFound {
+			return Order{}, apperr.NewError(apperr.CodeConstraint, errors.New("invalid couponCode specified"))
+		}
+		if err != nil {
+			return Order{}, fmt.Errorf("failed to lookup coupon: %w", err)
+		}
 	}
 	order := Order{
-		Items: req.Items,
+		Items:      req.Items,
+		Status:     StatusPlaced,
+		CreatedBy:  createdBy,
+		CustomerID: req.CustomerID,
+		LocationID: req.LocationID,
+	}
+	catalogue, err := s.catalogue(ctx, req.LocationID)
+	if err != nil {
+		return Order{}, err
+	}
+	order.Products, err = productsForItems(ctx, order.Items, catalogue)
+	if err != nil {
+		return Order{}, err
+	}
+	var discount money.Money
+	if req.CouponCode != "" {
+		var reason string
+		discount, reason = Discount(coupon, order.Items, order.Products)
+		order.Coupon = &CouponResult{Code: coupon.Code, Applied: reason == "", Reason: reason}
 	}
-	var err error
-	order.Products, err = productsForItems(ctx, order.Items, ps)
+	order.Totals, err = Price(order.Items, order.Products, discount)
 	if err != nil {
+		return Order{}, fmt.Errorf("failed to price order: %w", err)
+	}
+	// the ID is needed up front to redeem the coupon and reserve stock against
+	if order.ID, err = NewID(); err != nil {
 		return Order{}, err
 	}
-	return os.Create(ctx, order)
+	// every write is undone if a later one fails so a failed order takes nothing with it
+	var created Order
+	err = txn.Run(ctx, func(ctx context.Context) error {
+		if order.Coupon != nil && order.Coupon.Applied && s.Redemptions != nil {
+			use := coupons.Use{OrderID: order.ID, APIKey: createdBy, CustomerID: req.CustomerID}
+			if err := s.Redemptions.Redeem(ctx, coupon, use); err != nil {
+				return err
+			}
+		}
+		if s.Inventory != nil {
+			lines := make([]inventory.Line, 0, len(order.Items))
+			for _, v := range order.Items {
+				lines = append(lines, inventory.Line{ProductID: v.ProductID, Quantity: v.Quantity})
+			}
+			if err := s.Inventory.Reserve(ctx, order.ID, lines); err != nil {
+				return err
+			}
+		}
+		var err error
+		if created, err = s.Orders.Create(ctx, order); err != nil {
+			return err
+		}
+		// publishing can't be undone so it comes last
+		return s.publish(ctx, EventCreated, created)
+	})
+	if err != nil {
+		return Order{}, err
+	}
+	return created, nil
+}
+
+// catalogue returns the products sold at the location with id.
+func (s Service) catalogue(ctx context.Context, id string) (products.Store, error) {
+	if id == "" {
+		return s.Products, nil
+	}
+	if s.Locations == nil {
+		return nil, apperr.NewError(apperr.CodeConstraint, fmt.Errorf("location %s not found", id))
+	}
+	ps, err := s.Locations.Products(ctx, id)
+	var ae apperr.Error
+	if errors.As(err, &ae) && ae.Code == apperr.CodeNotFound {
+		return nil, apperr.NewError(apperr.CodeConstraint, fmt.Errorf("location %s not found", id))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup location: %w", err)
+	}
+	return ps, nil
+}
+
+// NewID returns a new unique order ID.
+func NewID() (string, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", fmt.Errorf("orders failed to create uuid: %w", err)
+	}
+	return id.String(), nil
 }
 
 func productsForItems(ctx context.Context, items []OrderItem, ps products.Store) ([]products.Product, error) {
@@ -85,7 +277,6 @@ func productsForItems(ctx context.Context, items []OrderItem, ps products.Store)
 	for _, v := range items {
 		prod, err := ps.Get(ctx, v.ProductID)
 		if err != nil {
-			fmt.Println("error")
 			var ae apperr.Error
 			if errors.As(err, &ae) && ae.Code == apperr.CodeNotFound {
 				return nil, apperr.NewError(apperr.CodeConstraint, errors.New("invalid product specified"))
@@ -1,10 +1,19 @@
 package orders
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/google/uuid"
+	"github.com/matgreaves/kart-challenge/api/apperr"
 	"github.com/matgreaves/kart-challenge/api/coupons"
+	"github.com/matgreaves/kart-challenge/api/inventory"
+	"github.com/matgreaves/kart-challenge/api/locations"
 	"github.com/matgreaves/kart-challenge/api/products"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -30,21 +39,41 @@ func TestCreate(t *testing.T) {
 		req.CouponCode = "OVER9000"
 		ps := products.NewSlice(products.SampleData)
 
-		o, err := Create(t.Context(), req, NewMem(), ps, coupons.Mem{"OVER9000": struct{}{}})
+		o, err := Service{Orders: NewMem(), Products: ps, Coupons: coupons.Mem{"OVER9000": struct{}{}}}.Create(t.Context(), "test", req)
 		require.NoError(t, err)
 
 		assert.NoError(t, uuid.Validate(o.ID))
 		assert.Equal(t, req.Items, o.Items)
+		assert.Equal(t, StatusPlaced, o.Status)
+		assert.Equal(t, "test", o.CreatedBy)
+		assert.False(t, o.CreatedAt.IsZero())
 		wantProduct, err := ps.Get(t.Context(), o.Items[0].ProductID)
 		require.NoError(t, err)
 		assert.Equal(t, []products.Product{wantProduct}, o.Products)
+		assert.Equal(t, &CouponResult{Code: "OVER9000", Applied: true}, o.Coupon)
+		assert.Equal(t, aud(65), o.Discount)
+		assert.Equal(t, aud(585), o.Total)
+	})
+
+	t.Run("coupon not applied", func(t *testing.T) {
+		t.Parallel()
+		req := testReq()
+		req.CouponCode = "BIGSPEND"
+		cs, err := coupons.NewRules(coupons.Coupon{Code: "BIGSPEND", Kind: coupons.KindFixed, Amount: aud(1000), MinSpend: aud(5000)})
+		require.NoError(t, err)
+
+		o, err := Service{Orders: NewMem(), Products: products.NewSlice(products.SampleData), Coupons: cs}.Create(t.Context(), "test", req)
+		require.NoError(t, err)
+		assert.Equal(t, &CouponResult{Code: "BIGSPEND", Reason: "order subtotal must be at least AUD 50.00"}, o.Coupon)
+		assert.True(t, o.Discount.IsZero())
+		assert.Equal(t, o.Subtotal, o.Total)
 	})
 
 	t.Run("no items", func(t *testing.T) {
 		t.Parallel()
 		req := testReq()
 		req.Items = nil
-		_, err := Create(t.Context(), req, nil, nil, nil)
+		_, err := Service{}.Create(t.Context(), "test", req)
 		assert.ErrorContains(t, err, "at least one item is required")
 	})
 
@@ -52,15 +81,170 @@ func TestCreate(t *testing.T) {
 		t.Parallel()
 		req := testReq()
 		req.Items[0].ProductID = ""
-		_, err := Create(t.Context(), req, nil, nil, nil)
+		_, err := Service{}.Create(t.Context(), "test", req)
 		assert.ErrorContains(t, err, "productId is required")
 	})
 
+	t.Run("duplicate items are combined", func(t *testing.T) {
+		t.Parallel()
+		req := testReq()
+		req.Items = append(req.Items, OrderItem{ProductID: "2", Quantity: 1}, OrderItem{ProductID: "1", Quantity: 2})
+
+		o, err := Service{Orders: NewMem(), Products: products.NewSlice(products.SampleData)}.Create(t.Context(), "test", req)
+		require.NoError(t, err)
+		assert.Equal(t, []OrderItem{{ProductID: "1", Quantity: 3}, {ProductID: "2", Quantity: 1}}, o.Items)
+		assert.Len(t, o.Products, 2)
+	})
+
+	t.Run("outside policy", func(t *testing.T) {
+		t.Parallel()
+		req := testReq()
+		req.Items[0].Quantity = 0
+		_, err := Service{}.Create(t.Context(), "test", req)
+		var ae apperr.Error
+		require.ErrorAs(t, err, &ae, "err must be an app error")
+		assert.Equal(t, apperr.CodeConstraint, ae.Code)
+		assert.ErrorContains(t, err, "item[0] quantity must be at least 1")
+	})
+
+	t.Run("zero policy has no limits", func(t *testing.T) {
+		t.Parallel()
+		req := testReq()
+		req.Items[0].Quantity = DefaultPolicy.MaxUnits + 1
+		o, err := Service{Orders: NewMem(), Products: products.NewSlice(products.SampleData), Policy: &Policy{}}.Create(t.Context(), "test", req)
+		require.NoError(t, err)
+		assert.Equal(t, req.Items, o.Items)
+	})
+
+	t.Run("failed order releases coupon", func(t *testing.T) {
+		t.Parallel()
+		cs, err := coupons.NewRules(coupons.Coupon{Code: "ONCE", Kind: coupons.KindPercentage, Percent: 10, Limits: coupons.Limits{MaxRedemptions: 1}})
+		require.NoError(t, err)
+		os := NewMem()
+		s := Service{
+			Orders:      os,
+			Products:    products.NewSlice(products.SampleData),
+			Coupons:     cs,
+			Redemptions: coupons.NewMemRedemptions(),
+		}
+		req := testReq()
+		req.CouponCode = "ONCE"
+
+		s.Orders = failingStore{Store: os}
+		_, err = s.Create(t.Context(), "test", req)
+		require.ErrorContains(t, err, "store unavailable")
+
+		s.Orders = os
+		_, err = s.Create(t.Context(), "test", req)
+		assert.NoError(t, err)
+	})
+
+	t.Run("not enough stock", func(t *testing.T) {
+		t.Parallel()
+		inv := inventory.NewMem(slog.New(slog.DiscardHandler))
+		_, err := inv.Set(t.Context(), inventory.Level{ProductID: "2", Available: 1})
+		require.NoError(t, err)
+		s := Service{
+			Orders:    NewMem(),
+			Products:  products.NewSlice(products.SampleData),
+			Inventory: inv,
+		}
+		req := OrderReq{Items: []OrderItem{{ProductID: "1", Quantity: 5}, {ProductID: "2", Quantity: 2}}}
+		_, err = s.Create(t.Context(), "test", req)
+		var ae apperr.Error
+		require.ErrorAs(t, err, &ae, "err must be an app error")
+		assert.Equal(t, apperr.CodeConstraint, ae.Code)
+		assert.EqualError(t, ae.Cause, "item[1] only 1 of product 2 in stock")
+
+		req.Items[1].Quantity = 1
+		_, err = s.Create(t.Context(), "test", req)
+		require.NoError(t, err)
+		l, err := inv.Get(t.Context(), "2")
+		require.NoError(t, err)
+		assert.Zero(t, l.Available)
+	})
+
+	t.Run("failed order releases stock", func(t *testing.T) {
+		t.Parallel()
+		inv := inventory.NewMem(slog.New(slog.DiscardHandler))
+		_, err := inv.Set(t.Context(), inventory.Level{ProductID: "1", Available: 1})
+		require.NoError(t, err)
+		os := NewMem()
+		s := Service{
+			Orders:    failingStore{Store: os},
+			Products:  products.NewSlice(products.SampleData),
+			Inventory: inv,
+		}
+		_, err = s.Create(t.Context(), "test", testReq())
+		require.ErrorContains(t, err, "store unavailable")
+
+		s.Orders = os
+		_, err = s.Create(t.Context(), "test", testReq())
+		assert.NoError(t, err)
+	})
+
+	t.Run("concurrent orders take the last use once", func(t *testing.T) {
+		t.Parallel()
+		cs, err := coupons.NewRules(coupons.Coupon{Code: "ONCE", Kind: coupons.KindPercentage, Percent: 10, Limits: coupons.Limits{MaxRedemptions: 1}})
+		require.NoError(t, err)
+		s := Service{
+			Orders:      NewMem(),
+			Products:    products.NewSlice(products.SampleData),
+			Coupons:     cs,
+			Redemptions: coupons.NewMemRedemptions(),
+		}
+		req := testReq()
+		req.CouponCode = "ONCE"
+
+		var placed atomic.Int32
+		wg := sync.WaitGroup{}
+		for range 20 {
+			wg.Go(func() {
+				_, err := s.Create(t.Context(), "test", req)
+				if err == nil {
+					placed.Add(1)
+					return
+				}
+				assert.ErrorContains(t, err, "coupon ONCE has no uses left")
+			})
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), placed.Load())
+	})
+
+	t.Run("coupon limited per customer", func(t *testing.T) {
+		t.Parallel()
+		cs, err := coupons.NewRules(coupons.Coupon{Code: "WELCOME", Kind: coupons.KindPercentage, Percent: 10, Limits: coupons.Limits{MaxPerCustomer: 1}})
+		require.NoError(t, err)
+		s := Service{
+			Orders:      NewMem(),
+			Products:    products.NewSlice(products.SampleData),
+			Coupons:     cs,
+			Redemptions: coupons.NewMemRedemptions(),
+		}
+		req := testReq()
+		req.CouponCode = "WELCOME"
+		req.CustomerID = "alice"
+		o, err := s.Create(t.Context(), "test", req)
+		require.NoError(t, err)
+		assert.Equal(t, "alice", o.CustomerID)
+
+		_, err = s.Create(t.Context(), "other", req)
+		var ae apperr.Error
+		require.ErrorAs(t, err, &ae, "err must be an app error")
+		assert.Equal(t, apperr.CodeConstraint, ae.Code)
+		assert.ErrorContains(t, err, "coupon WELCOME has no uses left for customer alice")
+
+		req.CustomerID = "bob"
+		_, err = s.Create(t.Context(), "test", req)
+		assert.NoError(t, err)
+	})
+
 	t.Run("coupon not in list", func(t *testing.T) {
 		t.Parallel()
 		req := testReq()
 		req.CouponCode = "UNDER9000"
-		_, err := Create(t.Context(), req, nil, nil, coupons.Mem{})
+		_, err := Service{Coupons: coupons.Mem{}}.Create(t.Context(), "test", req)
 		assert.ErrorContains(t, err, "invalid couponCode specified")
 	})
 
@@ -68,7 +252,234 @@ func TestCreate(t *testing.T) {
 		t.Parallel()
 		req := testReq()
 		req.Items[0].ProductID = "9999"
-		_, err := Create(t.Context(), req, nil, products.NewSlice(products.SampleData), nil)
+		_, err := Service{Products: products.NewSlice(products.SampleData)}.Create(t.Context(), "test", req)
+		assert.ErrorContains(t, err, "invalid product specified")
+	})
+
+	t.Run("location catalogue", func(t *testing.T) {
+		t.Parallel()
+		// the city location sells product 1 at a different price and doesn't sell product 2
+		city := products.Slice{{ID: "1", Name: "Waffle", Category: "Waffle", Price: aud(800)}}
+		ls, err := locations.NewMem(locations.Menu{Location: locations.Location{ID: "city"}, Products: city})
+		require.NoError(t, err)
+		s := Service{Orders: NewMem(), Products: products.NewSlice(products.SampleData), Locations: ls}
+
+		req := testReq()
+		req.LocationID = "city"
+		o, err := s.Create(t.Context(), "test", req)
+		require.NoError(t, err)
+		assert.Equal(t, "city", o.LocationID)
+		assert.Equal(t, []products.Product(city), o.Products)
+		assert.Equal(t, aud(800), o.Total)
+
+		req.Items[0].ProductID = "2"
+		_, err = s.Create(t.Context(), "test", req)
 		assert.ErrorContains(t, err, "invalid product specified")
+
+		// orders without a location use the default catalogue
+		o, err = s.Create(t.Context(), "test", testReq())
+		require.NoError(t, err)
+		assert.Empty(t, o.LocationID)
+		assert.Equal(t, aud(650), o.Total)
 	})
+
+	t.Run("location doesn't exist", func(t *testing.T) {
+		t.Parallel()
+		ls, err := locations.NewMem()
+		require.NoError(t, err)
+		req := testReq()
+		req.LocationID = "moon"
+		for _, s := range []Service{{}, {Locations: ls}} {
+			_, err := s.Create(t.Context(), "test", req)
+			var ae apperr.Error
+			require.ErrorAs(t, err, &ae, "err must be an app error")
+			assert.Equal(t, apperr.CodeConstraint, ae.Code)
+			assert.ErrorContains(t, err, "location moon not found")
+		}
+	})
+}
+
+func TestCreate_Rollback(t *testing.T) {
+	t.Parallel()
+
+	// each step of placing an order fails in turn, nothing done by the steps before it may remain
+	steps := map[string]func(s *Service){
+		"redeem coupon": func(s *Service) { s.Redemptions = failingRedemptions{s.Redemptions} },
+		"reserve stock": func(s *Service) { s.Inventory = failingInventory{s.Inventory} },
+		"create order":  func(s *Service) { s.Orders = failingStore{s.Orders} },
+		"publish event": func(s *Service) { s.Events = failingPublisher{} },
+	}
+	for step, fail := range steps {
+		t.Run(step, func(t *testing.T) {
+			t.Parallel()
+			cs, err := coupons.NewRules(coupons.Coupon{Code: "ONCE", Kind: coupons.KindPercentage, Percent: 10, Limits: coupons.Limits{MaxRedemptions: 1}})
+			require.NoError(t, err)
+			inv := inventory.NewMem(slog.New(slog.DiscardHandler))
+			_, err = inv.Set(t.Context(), inventory.Level{ProductID: "1", Available: 1})
+			require.NoError(t, err)
+			events := &recorder{}
+			s := Service{
+				Orders:      NewMem(),
+				Products:    products.NewSlice(products.SampleData),
+				Coupons:     cs,
+				Redemptions: coupons.NewMemRedemptions(),
+				Inventory:   inv,
+				Events:      events,
+			}
+			req := testReq()
+			req.CouponCode = "ONCE"
+
+			failing := s
+			fail(&failing)
+			_, err = failing.Create(t.Context(), "test", req)
+			require.ErrorContains(t, err, "unavailable")
+
+			p, err := s.Orders.List(t.Context(), ListQuery{Limit: MaxListLimit})
+			require.NoError(t, err)
+			assert.Empty(t, p.Orders, "order must not be stored")
+			l, err := inv.Get(t.Context(), "1")
+			require.NoError(t, err)
+			assert.Equal(t, 1, l.Available, "stock must be released")
+			assert.Empty(t, events.events, "no event may be published")
+
+			// the coupon's only use and the last unit of stock are still there to be taken
+			o, err := s.Create(t.Context(), "test", req)
+			require.NoError(t, err)
+			assert.True(t, o.Coupon.Applied)
+		})
+	}
+}
+
+func TestOrderReq_Validate(t *testing.T) {
+	t.Parallel()
+
+	policy := Policy{MinQuantity: 1, MaxQuantity: 5, MaxLines: 3, MaxUnits: 8}
+	items := func(qs ...int) []OrderItem {
+		items := []OrderItem{}
+		for i, q := range qs {
+			items = append(items, OrderItem{ProductID: fmt.Sprint(i + 1), Quantity: q})
+		}
+		return items
+	}
+
+	for _, tc := range []struct {
+		name    string
+		items   []OrderItem
+		policy  Policy
+		wantErr []string
+	}{
+		{
+			name:   "within policy",
+			items:  items(5, 1, 2),
+			policy: policy,
+		},
+		{
+			name:    "no items",
+			policy:  policy,
+			wantErr: []string{"at least one item is required"},
+		},
+		{
+			name:    "quantity out of range",
+			items:   items(0, 6, -1),
+			policy:  policy,
+			wantErr: []string{"item[0] quantity must be at least 1", "item[1] quantity must be at most 5", "item[2] quantity must be at least 1"},
+		},
+		{
+			name:    "negative quantity without minimum",
+			items:   items(0, -1),
+			policy:  Policy{},
+			wantErr: []string{"item[1] quantity cannot be less than zero"},
+		},
+		{
+			name:    "too many lines",
+			items:   items(1, 1, 1, 1),
+			policy:  policy,
+			wantErr: []string{"order cannot have more than 3 items"},
+		},
+		{
+			name:    "too many units",
+			items:   items(5, 4),
+			policy:  policy,
+			wantErr: []string{"order cannot have more than 8 units in total"},
+		},
+		{
+			name:    "duplicates combined over maximum",
+			items:   []OrderItem{{ProductID: "1", Quantity: 1}, {ProductID: "2", Quantity: 4}, {ProductID: "2", Quantity: 3}},
+			policy:  policy,
+			wantErr: []string{"item[1] quantity must be at most 5 once duplicate items are combined"},
+		},
+		{
+			name:    "missing productId",
+			items:   []OrderItem{{Quantity: 1}},
+			policy:  policy,
+			wantErr: []string{"item[0] productId is required"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			req := OrderReq{Items: tc.items}
+			err := req.Validate(tc.policy)
+			if len(tc.wantErr) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			for _, want := range tc.wantErr {
+				assert.ErrorContains(t, err, want)
+			}
+		})
+	}
+}
+
+func TestOrderReq_Normalise(t *testing.T) {
+	t.Parallel()
+
+	req := OrderReq{
+		CouponCode: "OVER9000",
+		Items: []OrderItem{
+			{ProductID: "2", Quantity: 1},
+			{ProductID: "1", Quantity: 2},
+			{ProductID: "2", Quantity: 3},
+		},
+	}
+	assert.Equal(t, OrderReq{
+		CouponCode: "OVER9000",
+		Items: []OrderItem{
+			{ProductID: "2", Quantity: 4},
+			{ProductID: "1", Quantity: 2},
+		},
+	}, req.Normalise())
+}
+
+// failingStore is a [Store] that fails to create any order.
+type failingStore struct {
+	Store
+}
+
+func (failingStore) Create(context.Context, Order) (Order, error) {
+	return Order{}, errors.New("store unavailable")
+}
+
+// failingRedemptions is a [coupons.Redemptions] that fails to redeem any coupon.
+type failingRedemptions struct {
+	coupons.Redemptions
+}
+
+func (failingRedemptions) Redeem(context.Context, coupons.Coupon, coupons.Use) error {
+	return errors.New("redemptions unavailable")
+}
+
+// failingInventory is an [inventory.Store] that fails to reserve any stock.
+type failingInventory struct {
+	inventory.Store
+}
+
+func (failingInventory) Reserve(context.Context, string, []inventory.Line) error {
+	return errors.New("inventory unavailable")
+}
+
+// failingPublisher is a [Publisher] that fails to publish any event.
+type failingPublisher struct{}
+
+func (failingPublisher) Publish(context.Context, Event) error {
+	return errors.New("events unavailable")
 }
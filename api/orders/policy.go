@@ -0,0 +1,31 @@
+package orders
+
+// DefaultPolicy is the [Policy] used when none is configured.
+var DefaultPolicy = Policy{
+	MinQuantity: 1,
+	MaxQuantity: 99,
+	MaxLines:    50,
+	MaxUnits:    500,
+}
+
+// Policy limits the size of orders that can be placed. Any maximum left as zero is not enforced.
+type Policy struct {
+	// MinQuantity is the smallest quantity allowed on a single line. Quantities can never be
+	// less than zero regardless of policy.
+	MinQuantity int
+	// MaxQuantity is the largest quantity allowed of a single product once duplicate lines
+	// are combined.
+	MaxQuantity int
+	// MaxLines is the largest number of lines allowed in an [OrderReq] as sent.
+	MaxLines int
+	// MaxUnits is the largest number of units allowed across every line of an order.
+	MaxUnits int
+}
+
+// policy returns the policy of s, [DefaultPolicy] when it has none.
+func (s Service) policy() Policy {
+	if s.Policy == nil {
+		return DefaultPolicy
+	}
+	return *s.Policy
+}
@@ -0,0 +1,71 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/matgreaves/kart-challenge/api/money"
+	"github.com/matgreaves/kart-challenge/api/products"
+)
+
+// LinePrice is the price of a single [OrderItem].
+type LinePrice struct {
+	ProductID string      `json:"productId"`
+	Quantity  int         `json:"quantity"`
+	UnitPrice money.Money `json:"unitPrice"`
+	Total     money.Money `json:"total"`
+}
+
+// Totals describes what a customer owes for an [Order].
+type Totals struct {
+	Lines []LinePrice `json:"lines,omitempty"`
+	// Subtotal is the sum of all line totals before any discount.
+	Subtotal money.Money `json:"subtotal"`
+	Discount money.Money `json:"discount"`
+	// Total is the amount payable, Subtotal less Discount.
+	Total money.Money `json:"total"`
+}
+
+// Price calculates the [Totals] for items where prods[i] is the product ordered by items[i].
+//
+// discount is taken off the subtotal but never takes the total below zero. Every product must be
+// priced in the same currency.
+func Price(items []OrderItem, prods []products.Product, discount money.Money) (Totals, error) {
+	if len(items) != len(prods) {
+		return Totals{}, fmt.Errorf("pricing %d items requires %d products, got %d", len(items), len(items), len(prods))
+	}
+	if discount.Amount < 0 {
+		return Totals{}, errors.New("discount cannot be less than zero")
+	}
+
+	t := Totals{Lines: make([]LinePrice, 0, len(items))}
+	for i, v := range items {
+		if v.ProductID != prods[i].ID {
+			return Totals{}, fmt.Errorf("item[%d] is for product %s but was priced with product %s", i, v.ProductID, prods[i].ID)
+		}
+		line, err := prods[i].Price.Mul(int64(v.Quantity))
+		if err != nil {
+			return Totals{}, fmt.Errorf("item[%d] could not be priced: %w", i, err)
+		}
+		if t.Subtotal, err = t.Subtotal.Add(line); err != nil {
+			return Totals{}, fmt.Errorf("item[%d] could not be priced: %w", i, err)
+		}
+		t.Lines = append(t.Lines, LinePrice{
+			ProductID: v.ProductID,
+			Quantity:  v.Quantity,
+			UnitPrice: prods[i].Price,
+			Total:     line,
+		})
+	}
+
+	var err error
+	if t.Discount, err = discount.Min(t.Subtotal); err != nil {
+		return Totals{}, fmt.Errorf("discount could not be applied: %w", err)
+	}
+	// a zero discount may not have a currency of its own
+	t.Discount.Currency = t.Subtotal.Currency
+	if t.Total, err = t.Subtotal.Sub(t.Discount); err != nil {
+		return Totals{}, fmt.Errorf("discount could not be applied: %w", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,82 @@
+package orders
+
+import (
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/money"
+	"github.com/matgreaves/kart-challenge/api/products"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrice(t *testing.T) {
+	t.Parallel()
+
+	items := []OrderItem{{ProductID: "1", Quantity: 3}, {ProductID: "2", Quantity: 1}}
+	prods := []products.Product{{ID: "1", Price: aud(10)}, {ID: "2", Price: aud(650)}}
+
+	t.Run("no discount", func(t *testing.T) {
+		t.Parallel()
+		got, err := Price(items, prods, money.Money{})
+		require.NoError(t, err)
+		assert.Equal(t, Totals{
+			Lines: []LinePrice{
+				{ProductID: "1", Quantity: 3, UnitPrice: aud(10), Total: aud(30)},
+				{ProductID: "2", Quantity: 1, UnitPrice: aud(650), Total: aud(650)},
+			},
+			Subtotal: aud(680),
+			Discount: aud(0),
+			Total:    aud(680),
+		}, got)
+	})
+
+	t.Run("discount", func(t *testing.T) {
+		t.Parallel()
+		got, err := Price(items, prods, aud(136))
+		require.NoError(t, err)
+		assert.Equal(t, aud(680), got.Subtotal)
+		assert.Equal(t, aud(136), got.Discount)
+		assert.Equal(t, aud(544), got.Total)
+	})
+
+	t.Run("discount larger than subtotal", func(t *testing.T) {
+		t.Parallel()
+		got, err := Price(items, prods, aud(10000))
+		require.NoError(t, err)
+		assert.Equal(t, aud(680), got.Discount)
+		assert.Equal(t, aud(0), got.Total)
+	})
+
+	t.Run("zero quantity", func(t *testing.T) {
+		t.Parallel()
+		got, err := Price([]OrderItem{{ProductID: "1"}}, prods[:1], money.Money{})
+		require.NoError(t, err)
+		assert.True(t, got.Lines[0].Total.IsZero())
+		assert.True(t, got.Total.IsZero())
+	})
+
+	t.Run("negative discount", func(t *testing.T) {
+		t.Parallel()
+		_, err := Price(items, prods, aud(-100))
+		assert.ErrorContains(t, err, "discount cannot be less than zero")
+	})
+
+	t.Run("products don't match items", func(t *testing.T) {
+		t.Parallel()
+		_, err := Price(items, prods[:1], money.Money{})
+		assert.ErrorContains(t, err, "pricing 2 items requires 2 products, got 1")
+
+		_, err = Price(items, []products.Product{prods[1], prods[0]}, money.Money{})
+		assert.ErrorContains(t, err, "item[0] is for product 1 but was priced with product 2")
+	})
+
+	t.Run("mixed currencies", func(t *testing.T) {
+		t.Parallel()
+		_, err := Price(items, []products.Product{prods[0], {ID: "2", Price: money.New(650, "USD")}}, money.Money{})
+		assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+	})
+}
+
+func aud(cents int64) money.Money {
+	return money.New(cents, "AUD")
+}
@@ -0,0 +1,90 @@
+package orders
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/txn"
+)
+
+// Status is the point an [Order] has reached in its lifecycle.
+type Status string
+
+const (
+	StatusPlaced    Status = "placed"
+	StatusAccepted  Status = "accepted"
+	StatusPreparing Status = "preparing"
+	StatusReady     Status = "ready"
+	StatusCompleted Status = "completed"
+	StatusCancelled Status = "cancelled"
+	StatusRejected  Status = "rejected"
+)
+
+// statuses lists every known [Status] and the statuses an order may move to from it.
+//
+//	placed → accepted → preparing → ready → completed
+//	placed → rejected
+//	placed, accepted → cancelled
+var statuses = map[Status][]Status{
+	StatusPlaced:    {StatusAccepted, StatusRejected, StatusCancelled},
+	StatusAccepted:  {StatusPreparing, StatusCancelled},
+	StatusPreparing: {StatusReady},
+	StatusReady:     {StatusCompleted},
+	StatusCompleted: {},
+	StatusCancelled: {},
+	StatusRejected:  {},
+}
+
+// CanTransition reports whether an order can move from one status to another.
+func CanTransition(from, to Status) bool {
+	return slices.Contains(statuses[from], to)
+}
+
+// StatusChange is a single entry in an [Order]'s history.
+type StatusChange struct {
+	From Status    `json:"from"`
+	To   Status    `json:"to"`
+	At   time.Time `json:"at"`
+	// Actor identifies the principal that made the change.
+	Actor string `json:"actor,omitempty"`
+}
+
+// Transition moves the order with id to status to on behalf of actor, recording the change in
+// the order's history. Rejecting an order releases its coupon and stock as [Service.Cancel] does.
+//
+// Returns an [apperr.CodeConstraint] error if the order can't move to the requested status.
+func (s Service) Transition(ctx context.Context, id string, to Status, actor string) (Order, error) {
+	var o Order
+	err := txn.Run(ctx, func(ctx context.Context) error {
+		var err error
+		o, err = s.Orders.Update(ctx, id, func(o Order) (Order, error) {
+			return transition(o, to, actor)
+		})
+		if err != nil || o.Status != StatusRejected {
+			return err
+		}
+		return s.release(ctx, o)
+	})
+	if err != nil {
+		return Order{}, err
+	}
+	s.publishSaved(ctx, EventStatusChanged, o)
+	return o, nil
+}
+
+func transition(o Order, to Status, actor string) (Order, error) {
+	if !CanTransition(o.Status, to) {
+		return Order{}, apperr.NewError(apperr.CodeConstraint, fmt.Errorf("order cannot move from %s to %s", o.Status, to))
+	}
+	o.History = append(slices.Clip(o.History), StatusChange{
+		From:  o.Status,
+		To:    to,
+		At:    time.Now().UTC(),
+		Actor: actor,
+	})
+	o.Status = to
+	return o, nil
+}
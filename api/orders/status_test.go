@@ -0,0 +1,120 @@
+package orders
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/coupons"
+	"github.com/matgreaves/kart-challenge/api/inventory"
+	"github.com/matgreaves/kart-challenge/api/products"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransition(t *testing.T) {
+	t.Parallel()
+
+	t.Run("full lifecycle", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem()
+		o, err := m.Create(t.Context(), Order{Items: testReq().Items, Status: StatusPlaced})
+		require.NoError(t, err)
+
+		path := []Status{StatusAccepted, StatusPreparing, StatusReady, StatusCompleted}
+		for _, to := range path {
+			o, err = Service{Orders: m}.Transition(t.Context(), o.ID, to, "staff")
+			require.NoError(t, err)
+			assert.Equal(t, to, o.Status)
+		}
+
+		require.Len(t, o.History, len(path))
+		from := StatusPlaced
+		for i, v := range o.History {
+			assert.Equal(t, from, v.From)
+			assert.Equal(t, path[i], v.To)
+			assert.Equal(t, "staff", v.Actor)
+			assert.False(t, v.At.IsZero())
+			if i > 0 {
+				assert.False(t, v.At.Before(o.History[i-1].At))
+			}
+			from = v.To
+		}
+	})
+
+	t.Run("illegal transition", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem()
+		o, err := m.Create(t.Context(), Order{Items: testReq().Items, Status: StatusPlaced})
+		require.NoError(t, err)
+
+		_, err = Service{Orders: m}.Transition(t.Context(), o.ID, StatusReady, "staff")
+		ae, ok := err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, apperr.CodeConstraint, ae.Code)
+		assert.ErrorContains(t, err, "order cannot move from placed to ready")
+
+		stored, err := m.Get(t.Context(), o.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusPlaced, stored.Status)
+		assert.Empty(t, stored.History)
+	})
+
+	t.Run("rejecting releases coupon and stock", func(t *testing.T) {
+		t.Parallel()
+		cs, err := coupons.NewRules(coupons.Coupon{Code: "ONCE", Kind: coupons.KindPercentage, Percent: 10, Limits: coupons.Limits{MaxRedemptions: 1}})
+		require.NoError(t, err)
+		inv := inventory.NewMem(slog.New(slog.DiscardHandler))
+		_, err = inv.Set(t.Context(), inventory.Level{ProductID: "1", Available: 1})
+		require.NoError(t, err)
+		s := Service{
+			Orders:      NewMem(),
+			Products:    products.NewSlice(products.SampleData),
+			Coupons:     cs,
+			Redemptions: coupons.NewMemRedemptions(),
+			Inventory:   inv,
+		}
+		req := testReq()
+		req.CouponCode = "ONCE"
+
+		first, err := s.Create(t.Context(), "customer", req)
+		require.NoError(t, err)
+		_, err = s.Create(t.Context(), "customer", req)
+		assert.ErrorContains(t, err, "coupon ONCE has no uses left")
+		_, err = s.Create(t.Context(), "customer", testReq())
+		assert.ErrorContains(t, err, "item[0] only 0 of product 1 in stock")
+
+		o, err := s.Transition(t.Context(), first.ID, StatusRejected, "staff")
+		require.NoError(t, err)
+		assert.Equal(t, StatusRejected, o.Status)
+		_, err = s.Create(t.Context(), "customer", req)
+		assert.NoError(t, err, "coupon and stock are free again")
+	})
+
+	t.Run("failed release keeps order", func(t *testing.T) {
+		t.Parallel()
+		s := releaseFailingService(t)
+		o, err := s.Create(t.Context(), "customer", onceReq())
+		require.NoError(t, err)
+
+		_, err = s.Transition(t.Context(), o.ID, StatusRejected, "staff")
+		assert.ErrorContains(t, err, "failed to release stock")
+
+		stored, err := s.Orders.Get(t.Context(), o.ID)
+		require.NoError(t, err)
+		assert.Equal(t, o, stored)
+		_, err = s.Create(t.Context(), "customer", onceReq())
+		assert.ErrorContains(t, err, "coupon ONCE has no uses left", "released coupon is taken back")
+	})
+}
+
+func TestCanTransition(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, CanTransition(StatusPlaced, StatusRejected))
+	assert.True(t, CanTransition(StatusAccepted, StatusCancelled))
+	assert.False(t, CanTransition(StatusPreparing, StatusCancelled))
+	assert.False(t, CanTransition(StatusCompleted, StatusPlaced))
+	assert.False(t, CanTransition(StatusRejected, StatusAccepted))
+	assert.False(t, CanTransition("lost", StatusAccepted))
+}
@@ -0,0 +1,33 @@
+package orders_test
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/orders"
+	"github.com/matgreaves/kart-challenge/api/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreConformance(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Mem", func(t *testing.T) {
+		t.Parallel()
+		storetest.Orders(t, func(t *testing.T) orders.Store {
+			return orders.NewMem()
+		})
+	})
+
+	t.Run("File", func(t *testing.T) {
+		t.Parallel()
+		storetest.Orders(t, func(t *testing.T) orders.Store {
+			// snapshot often so compaction happens part way through the suite
+			f, err := orders.OpenFile(t.TempDir(), 2, slog.New(slog.DiscardHandler))
+			require.NoError(t, err)
+			t.Cleanup(func() { assert.NoError(t, f.Close()) })
+			return f
+		})
+	})
+}
@@ -0,0 +1,269 @@
+package products
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/matgreaves/kart-challenge/api/categories"
+	"github.com/matgreaves/kart-challenge/api/money"
+)
+
+// DefaultReloadInterval is how often a [File] checks whether its catalogue has changed.
+const DefaultReloadInterval = 5 * time.Second
+
+// csvColumns are the columns a CSV catalogue must have, in any order. Any other column is ignored.
+var csvColumns = []string{"id", "name", "category", "price"}
+
+var _ Store = &File{}
+
+// File is a [Store] that serves an [Index] of a catalogue loaded from disk, see [Load] for
+// supported formats.
+//
+// [File.Watch] reloads the catalogue whenever it changes. A catalogue that fails to load or
+// validate is rejected and the last good catalogue keeps being served.
+type File struct {
+	path       string
+	categories []categories.Category
+	logger     *slog.Logger
+	current    atomic.Pointer[Index]
+
+	// mu guards version.
+	mu sync.Mutex
+	// version identifies the files last loaded, good or bad, so a bad catalogue is only
+	// reported once.
+	version string
+}
+
+// OpenFile loads the catalogue at path, which must be valid for cs. Reloads are logged to logger.
+func OpenFile(path string, cs []categories.Category, logger *slog.Logger) (*File, error) {
+	f := &File{path: path, categories: cs, logger: logger}
+	version, err := catalogueVersion(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Load(path, cs)
+	if err != nil {
+		return nil, err
+	}
+	f.current.Store(NewIndex(s).replacing(&Index{}, time.Now()))
+	f.version = version
+	return f, nil
+}
+
+// Get implements [Store.Get].
+func (f *File) Get(ctx context.Context, id string) (Product, error) {
+	return f.current.Load().Get(ctx, id)
+}
+
+// List implements [Store.List].
+func (f *File) List(ctx context.Context, page, pageSize int) ([]Product, error) {
+	return f.current.Load().List(ctx, page, pageSize)
+}
+
+// Search implements [Store.Search].
+func (f *File) Search(ctx context.Context, q Query) (Page, error) {
+	return f.current.Load().Search(ctx, q)
+}
+
+// Version implements [Store.Version], the version is Modified whenever a catalogue with different
+// products is loaded.
+func (f *File) Version(ctx context.Context) (Version, error) {
+	return f.current.Load().Version(ctx)
+}
+
+// Watch checks for changes to the catalogue every interval reloading it when it has changed.
+// Blocks until ctx is cancelled. An interval of zero or less never checks and returns at once.
+func (f *File) Watch(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return nil
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+			f.Reload(ctx)
+		}
+	}
+}
+
+// Reload loads the catalogue again if it has changed since it was last loaded.
+func (f *File) Reload(ctx context.Context) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	version, err := catalogueVersion(f.path)
+	if err != nil {
+		f.logger.ErrorContext(ctx, "failed to check product catalogue for changes", slog.String("path", f.path), slog.String("error", err.Error()))
+		return
+	}
+	if version == f.version {
+		return
+	}
+	f.version = version
+	s, err := Load(f.path, f.categories)
+	if err != nil {
+		f.logger.ErrorContext(ctx, "product catalogue reload rejected, serving previous catalogue", slog.String("path", f.path), slog.String("error", err.Error()))
+		return
+	}
+	f.current.Store(NewIndex(s).replacing(f.current.Load(), time.Now()))
+	f.logger.InfoContext(ctx, "product catalogue reloaded", slog.String("path", f.path), slog.Int("products", len(s)))
+}
+
+// Load reads the catalogue at path, maps its categories to their IDs, and validates it for cs,
+// see [Product.CategoryID] and [Slice.Validate].
+//
+// path is either a single file or a directory of files, a directory is read in name order and
+// files other than .json and .csv are ignored. JSON files hold a list of [Product]. CSV files
+// have a header row naming the id, name, category and price columns with prices in AUD.
+func Load(path string, cs []categories.Category) (Slice, error) {
+	files, err := catalogueFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	s := Slice{}
+	for _, v := range files {
+		ps, err := loadFile(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", v, err)
+		}
+		s = append(s, ps...)
+	}
+	s = s.withCategoryIDs()
+	if err := s.Validate(cs); err != nil {
+		return nil, fmt.Errorf("invalid product catalogue %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Validate checks every product in s is valid for cs and has a unique ID, see [Product.Validate].
+func (s Slice) Validate(cs []categories.Category) error {
+	ve := []error{}
+	known := categoryIDs(cs)
+	if len(s) == 0 {
+		ve = append(ve, errors.New("at least one product is required"))
+	}
+	seen := map[string]struct{}{}
+	for i, v := range s {
+		if _, has := seen[v.ID]; has && v.ID != "" {
+			ve = append(ve, fmt.Errorf("product[%d] id %s is duplicated", i, v.ID))
+		}
+		seen[v.ID] = struct{}{}
+		for _, err := range v.problems(known) {
+			ve = append(ve, fmt.Errorf("product[%d] %w", i, err))
+		}
+	}
+	if len(ve) > 0 {
+		return errors.Join(ve...)
+	}
+	return nil
+}
+
+// catalogueFiles lists the files making up the catalogue at path.
+func catalogueFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read product catalogue: %w", err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read product catalogue: %w", err)
+	}
+	files := []string{}
+	for _, v := range entries {
+		ext := strings.ToLower(filepath.Ext(v.Name()))
+		if v.Type().IsRegular() && (ext == ".json" || ext == ".csv") {
+			files = append(files, filepath.Join(path, v.Name()))
+		}
+	}
+	// ReadDir returns entries in name order
+	return files, nil
+}
+
+// catalogueVersion summarises the size and modification time of every file making up the
+// catalogue at path, it changes whenever the catalogue does.
+func catalogueVersion(path string) (string, error) {
+	files, err := catalogueFiles(path)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, v := range files {
+		info, err := os.Stat(v)
+		if err != nil {
+			return "", fmt.Errorf("failed to read product catalogue: %w", err)
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", v, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+func loadFile(path string) ([]Product, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return decodeCSV(f)
+	}
+	var ps []Product
+	if err := json.NewDecoder(f).Decode(&ps); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+func decodeCSV(r io.Reader) ([]Product, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	index := map[string]int{}
+	for i, v := range header {
+		index[strings.ToLower(strings.TrimSpace(v))] = i
+	}
+	for _, v := range csvColumns {
+		if _, has := index[v]; !has {
+			return nil, fmt.Errorf("header is missing the %s column", v)
+		}
+	}
+	ps := []Product{}
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return ps, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		price, err := money.Parse(row[index["price"]], money.DefaultCurrency)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ps = append(ps, Product{
+			ID:       row[index["id"]],
+			Name:     row[index["name"]],
+			Category: row[index["category"]],
+			Price:    price,
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package products
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matgreaves/kart-challenge/api/categories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCSV = `id,name,category,price
+1,Black Forest,Cake,7.50
+2,Carrot,Cake,8
+3,Red Velvet,Cake,5.00
+`
+
+// testCategories are the categories of the test products.
+var testCategories = []categories.Category{{ID: "cake", Name: "Cakes"}, {ID: "tart", Name: "Tarts"}}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "products.json")
+		writeFile(t, path, string(SampleData))
+		cs, err := categories.Parse(categories.SampleData)
+		require.NoError(t, err)
+		s, err := Load(path, cs)
+		require.NoError(t, err)
+		assert.Equal(t, NewSlice(SampleData), s)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "products.csv")
+		writeFile(t, path, testCSV)
+		s, err := Load(path, testCategories)
+		require.NoError(t, err)
+		assert.Equal(t, Slice(testProducts), s)
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		b, err := json.Marshal([]Product{{ID: "4", Name: "Lemon", Category: "Tart", Price: testProducts[0].Price}})
+		require.NoError(t, err)
+		writeFile(t, filepath.Join(dir, "a.csv"), testCSV)
+		writeFile(t, filepath.Join(dir, "b.json"), string(b))
+		writeFile(t, filepath.Join(dir, "README.md"), "ignored")
+		s, err := Load(dir, testCategories)
+		require.NoError(t, err)
+		assert.Len(t, s, 4)
+		assert.Equal(t, "4", s[3].ID)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		for name, tc := range map[string]struct {
+			content string
+			err     string
+		}{
+			"bad.csv":       {"id,name,category,price\n1,Cake,Cake,abc\n", `line 2: money: invalid amount "abc"`},
+			"header.csv":    {"id,name,price\n1,Cake,1\n", "header is missing the category column"},
+			"bad.json":      {`{"id":"1"}`, "cannot unmarshal object"},
+			"invalid.json":  {`[{"id":"1","price":1,"category":"Cake"},{"id":"1","name":"Cake","category":"Cake"}]`, "product[0] name is required\nproduct[1] id 1 is duplicated\nproduct[1] price must be greater than zero"},
+			"category.json": {`[{"id":"1","name":"Cake","price":1},{"id":"2","name":"Pie","price":1,"category":"Pie"}]`, "product[0] category is required\nproduct[1] category Pie not found"},
+			"empty.json":    {`[]`, "at least one product is required"},
+		} {
+			path := filepath.Join(dir, name)
+			writeFile(t, path, tc.content)
+			_, err := Load(path, testCategories)
+			assert.ErrorContains(t, err, tc.err, name)
+		}
+	})
+}
+
+func TestFile_Reload(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "products.csv")
+	writeFile(t, path, testCSV)
+	logs := &bytes.Buffer{}
+	f, err := OpenFile(path, testCategories, slog.New(slog.NewJSONHandler(logs, nil)))
+	require.NoError(t, err)
+
+	f.Reload(t.Context())
+	assert.Empty(t, logs.String(), "nothing changed")
+
+	writeFile(t, path, testCSV+"4,Lemon,Tart,6.00\n")
+	f.Reload(t.Context())
+	p, err := f.Get(t.Context(), "4")
+	require.NoError(t, err)
+	assert.Equal(t, "Lemon", p.Name)
+	assert.Contains(t, logs.String(), "product catalogue reloaded")
+
+	writeFile(t, path, testCSV+"4,,Tart,6.00\n5,Lime,Tart,-1\n")
+	f.Reload(t.Context())
+	p, err = f.Get(t.Context(), "4")
+	require.NoError(t, err)
+	assert.Equal(t, "Lemon", p.Name, "bad catalogue rejected")
+	assert.Contains(t, logs.String(), "product catalogue reload rejected")
+
+	_, err = OpenFile(path, testCategories, slog.New(slog.DiscardHandler))
+	assert.Error(t, err, "bad catalogue can't be opened")
+}
+
+func TestFile_Watch(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "products.csv")
+	writeFile(t, path, testCSV)
+	f, err := OpenFile(path, testCategories, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		assert.NoError(t, f.Watch(t.Context(), interval), "%s never checks", interval)
+	}
+}
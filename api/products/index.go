@@ -0,0 +1,148 @@
+package products
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+)
+
+var _ Store = &Index{}
+
+// Index is a [Store] with constant time lookups by ID and an index of products by category.
+//
+// Products are listed in the order they were given, the same order as a [Slice] of the same
+// products, so the two can be swapped freely. Build one from [SampleData] with
+// NewIndex(NewSlice(SampleData)).
+type Index struct {
+	products []Product
+	byID     map[string]int
+	// byCategory holds the position of each product in products, in order.
+	byCategory map[string][]int
+	version    Version
+}
+
+// NewIndex indexes ps. When more than one product has the same ID the first is returned by
+// [Index.Get], matching [Slice]. The index doesn't know when ps last changed so its version is
+// never Modified.
+func NewIndex(ps []Product) *Index {
+	idx := &Index{
+		products:   ps,
+		byID:       make(map[string]int, len(ps)),
+		byCategory: map[string][]int{},
+		version:    Version{Hash: versionHash(ps)},
+	}
+	for i, v := range ps {
+		if _, has := idx.byID[v.ID]; !has {
+			idx.byID[v.ID] = i
+		}
+		idx.byCategory[v.CategoryID] = append(idx.byCategory[v.CategoryID], i)
+	}
+	return idx
+}
+
+// replacing returns idx as the successor of prev, the version is Modified at now when the products
+// differ from prev's and is prev's version otherwise. prev is an empty Index for the first.
+func (idx *Index) replacing(prev *Index, now time.Time) *Index {
+	if idx.version.Hash == prev.version.Hash {
+		idx.version = prev.version
+		return idx
+	}
+	now = now.UTC()
+	idx.version.Modified = now
+	idx.version.SameSecond = now.Truncate(time.Second).Equal(prev.version.Modified.Truncate(time.Second))
+	return idx
+}
+
+// Get implements [Store.Get].
+func (idx *Index) Get(ctx context.Context, id string) (Product, error) {
+	if err := ctx.Err(); err != nil {
+		return Product{}, err
+	}
+	i, has := idx.byID[id]
+	if !has {
+		return Product{}, apperr.NewError(apperr.CodeNotFound, fmt.Errorf("product %s not found", id))
+	}
+	return idx.products[i], nil
+}
+
+// List implements [Store.List].
+func (idx *Index) List(ctx context.Context, page, pageSize int) ([]Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := validatePage(page, pageSize); err != nil {
+		return nil, err
+	}
+	return idx.products[min(pageSize*page, len(idx.products)):min((pageSize*page)+pageSize, len(idx.products))], nil
+}
+
+// Search implements [Store.Search]. A query for a category only looks at the products in it.
+func (idx *Index) Search(ctx context.Context, q Query) (Page, error) {
+	if q.Category == "" {
+		return search(ctx, idx.products, q)
+	}
+	positions := idx.byCategory[q.Category]
+	ps := make([]Product, len(positions))
+	for i, v := range positions {
+		ps[i] = idx.products[v]
+	}
+	return search(ctx, ps, q)
+}
+
+// Version implements [Store.Version].
+func (idx *Index) Version(ctx context.Context) (Version, error) {
+	if err := ctx.Err(); err != nil {
+		return Version{}, err
+	}
+	return idx.version, nil
+}
+
+// Category lists the products with the category ID category, paginated the same as [Index.List].
+func (idx *Index) Category(ctx context.Context, category string, page, pageSize int) ([]Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := validatePage(page, pageSize); err != nil {
+		return nil, err
+	}
+	positions := idx.byCategory[category]
+	positions = positions[min(pageSize*page, len(positions)):min((pageSize*page)+pageSize, len(positions))]
+	ps := make([]Product, 0, len(positions))
+	for _, i := range positions {
+		ps = append(ps, idx.products[i])
+	}
+	return ps, nil
+}
+
+// Len returns the number of products in idx.
+func (idx *Index) Len() int {
+	return len(idx.products)
+}
+
+// validatePage checks the arguments to [Store.List].
+func validatePage(page, pageSize int) error {
+	if page < 0 {
+		return apperr.Error{
+			Code:  apperr.CodeValidation,
+			Cause: errors.New("page must be zero or greater"),
+		}
+	}
+	if pageSize < 1 {
+		return apperr.Error{
+			Code:  apperr.CodeValidation,
+			Cause: errors.New("pageSize must be greater than zero"),
+		}
+	}
+	// the end of the page, (page+1)*pageSize, must not overflow
+	if page >= math.MaxInt/pageSize {
+		return apperr.Error{
+			Code:  apperr.CodeValidation,
+			Cause: errors.New("page is too large"),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package products
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex_Get(t *testing.T) {
+	t.Parallel()
+	s := NewSlice(SampleData)
+	idx := NewIndex(s)
+	for _, v := range s {
+		p, err := idx.Get(t.Context(), v.ID)
+		require.NoError(t, err)
+		assert.Equal(t, v, p)
+	}
+
+	_, err := idx.Get(t.Context(), "9001")
+	ae, ok := err.(apperr.Error)
+	require.True(t, ok, "err must be an app error")
+	assert.Equal(t, apperr.CodeNotFound, ae.Code)
+	assert.ErrorContains(t, err, "product 9001 not found")
+}
+
+func TestIndex_List(t *testing.T) {
+	t.Parallel()
+	s := NewSlice(SampleData)
+	idx := NewIndex(s)
+	for _, page := range []struct{ page, size int }{{0, 1}, {1, 3}, {2, 4}, {0, 100}, {100, 100}} {
+		want, err := s.List(t.Context(), page.page, page.size)
+		require.NoError(t, err)
+		got, err := idx.List(t.Context(), page.page, page.size)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, "page %d size %d matches slice", page.page, page.size)
+	}
+
+	_, err := idx.List(t.Context(), -1, 1)
+	assert.ErrorContains(t, err, "page must be zero or greater")
+	_, err = idx.List(t.Context(), 0, 0)
+	assert.ErrorContains(t, err, "pageSize must be greater than zero")
+}
+
+func TestIndex_Category(t *testing.T) {
+	t.Parallel()
+	idx := NewIndex(append(testProducts, Product{ID: "4", Category: "Tart", CategoryID: "tart", Name: "Lemon"}, Product{ID: "5", Category: "Cake", CategoryID: "cake", Name: "Sponge"}))
+
+	p, err := idx.Category(t.Context(), "cake", 0, 10)
+	require.NoError(t, err)
+	ids := []string{}
+	for _, v := range p {
+		ids = append(ids, v.ID)
+	}
+	assert.Equal(t, []string{"1", "2", "3", "5"}, ids, "in list order")
+
+	p, err = idx.Category(t.Context(), "cake", 1, 3)
+	require.NoError(t, err)
+	require.Len(t, p, 1)
+	assert.Equal(t, "5", p[0].ID)
+
+	p, err = idx.Category(t.Context(), "pie", 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, p)
+}
+
+func TestIndex_Search(t *testing.T) {
+	t.Parallel()
+	ps := append(slices.Clone(testProducts), Product{ID: "4", Category: "Tart", CategoryID: "tart", Name: "Lemon", Price: money.New(600, "AUD")})
+	idx := NewIndex(ps)
+
+	for _, q := range []Query{
+		{Category: "cake", PageSize: 10},
+		{Category: "cake", Sort: "-price", PageSize: 2, Page: 1},
+		{Category: "tart", Name: "lemon", PageSize: 10},
+		{Category: "pie", PageSize: 10},
+	} {
+		want, err := Slice(ps).Search(t.Context(), q)
+		require.NoError(t, err)
+		got, err := idx.Search(t.Context(), q)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, "the same as searching every product for %+v", q)
+	}
+}
+
+// catalogue returns n products spread over 20 categories.
+func catalogue(n int) []Product {
+	ps := make([]Product, 0, n)
+	for i := range n {
+		ps = append(ps, Product{
+			ID:       strconv.Itoa(i),
+			Name:     fmt.Sprintf("Product %d", i),
+			Category: fmt.Sprintf("Category %d", i%20),
+			Price:    money.New(int64(100+i), money.DefaultCurrency),
+		})
+	}
+	return ps
+}
+
+func BenchmarkGet(b *testing.B) {
+	ps := catalogue(10_000)
+	for name, s := range map[string]Store{"slice": Slice(ps), "index": NewIndex(ps)} {
+		b.Run(name, func(b *testing.B) {
+			i := 0
+			for b.Loop() {
+				if _, err := s.Get(b.Context(), ps[i%len(ps)].ID); err != nil {
+					b.Fatal(err)
+				}
+				i += 7919 // visit products all over the catalogue
+			}
+		})
+	}
+}
+
+func BenchmarkCategory(b *testing.B) {
+	ps := catalogue(10_000)
+	b.Run("slice", func(b *testing.B) {
+		s := Slice(ps)
+		for b.Loop() {
+			matched := []Product{}
+			for _, v := range s {
+				if v.Category == "Category 7" {
+					matched = append(matched, v)
+				}
+			}
+			_ = matched
+		}
+	})
+	b.Run("index", func(b *testing.B) {
+		idx := NewIndex(ps)
+		for b.Loop() {
+			if _, err := idx.Category(b.Context(), "Category 7", 0, len(ps)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestIndex_replacing(t *testing.T) {
+	t.Parallel()
+	s := NewSlice(SampleData)
+	start := time.Date(2025, 10, 1, 9, 0, 0, 500_000_000, time.UTC)
+	first := NewIndex(s).replacing(&Index{}, start)
+	assert.Equal(t, Version{Hash: versionHash(s), Modified: start}, first.version, "first version is modified")
+
+	same := NewIndex(slices.Clone(s)).replacing(first, start.Add(time.Minute))
+	assert.Equal(t, first.version, same.version, "same products aren't modified")
+
+	changed := slices.Clone(s)
+	changed[0].Price = money.New(760, "AUD")
+	next := NewIndex(changed).replacing(first, start.Add(300*time.Millisecond))
+	assert.Equal(t, start.Add(300*time.Millisecond), next.version.Modified)
+	assert.True(t, next.version.SameSecond, "changed again in the same second")
+
+	later := NewIndex(s).replacing(next, start.Add(time.Second))
+	assert.Equal(t, start.Add(time.Second), later.version.Modified)
+	assert.False(t, later.version.SameSecond, "changed in a later second")
+}
@@ -0,0 +1,143 @@
+package products
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/categories"
+)
+
+// WriteStore is a [Store] that products can be added to, changed in and retired from.
+type WriteStore interface {
+	Store
+	// Create adds p to the catalogue returning an [apperr.CodeValidation] error if it isn't valid
+	// or an [apperr.CodeConstraint] error if there's already a product with its ID or one with its
+	// ID has been retired.
+	Create(ctx context.Context, p Product) (Product, error)
+	// Update replaces the product with id with the result of fn, returning an
+	// [apperr.CodeNotFound] error if there isn't one or an [apperr.CodeValidation] error if the
+	// result isn't valid. Any change to the ID is ignored and an error from fn is returned as is.
+	Update(ctx context.Context, id string, fn func(p Product) (Product, error)) (Product, error)
+	// Retire removes the product with id from the catalogue returning it, or an
+	// [apperr.CodeNotFound] error if there isn't one. Orders already placed keep their own copy of
+	// the product so are unaffected. The ID of a retired product can't be used again.
+	Retire(ctx context.Context, id string) (Product, error)
+}
+
+var _ WriteStore = &Mem{}
+
+// Mem is a [WriteStore] held in memory. Reads are served from an [Index] that is rebuilt on every
+// write so they never wait on writes.
+type Mem struct {
+	categories []categories.Category
+	// mu serialises writes and guards retired.
+	mu sync.Mutex
+	// retired holds the IDs of retired products so they're never reused.
+	retired map[string]struct{}
+	current atomic.Pointer[Index]
+}
+
+// NewMem creates a [Mem] store of ps which, once its categories are mapped to their IDs, must be
+// valid for the categories cs, see [Product.CategoryID] and [Slice.Validate]. Products written
+// to it must also be in one of cs and have their categories mapped the same way.
+func NewMem(ps []Product, cs []categories.Category) (*Mem, error) {
+	ps = Slice(ps).withCategoryIDs()
+	if err := Slice(ps).Validate(cs); err != nil {
+		return nil, err
+	}
+	m := &Mem{categories: cs, retired: map[string]struct{}{}}
+	m.current.Store(NewIndex(ps).replacing(&Index{}, time.Now()))
+	return m, nil
+}
+
+// Get implements [Store.Get].
+func (m *Mem) Get(ctx context.Context, id string) (Product, error) {
+	return m.current.Load().Get(ctx, id)
+}
+
+// List implements [Store.List].
+func (m *Mem) List(ctx context.Context, page, pageSize int) ([]Product, error) {
+	return m.current.Load().List(ctx, page, pageSize)
+}
+
+// Search implements [Store.Search].
+func (m *Mem) Search(ctx context.Context, q Query) (Page, error) {
+	return m.current.Load().Search(ctx, q)
+}
+
+// Version implements [Store.Version], the version is Modified by every write that changes the
+// products.
+func (m *Mem) Version(ctx context.Context) (Version, error) {
+	return m.current.Load().Version(ctx)
+}
+
+// Create implements [WriteStore.Create].
+func (m *Mem) Create(ctx context.Context, p Product) (Product, error) {
+	if err := ctx.Err(); err != nil {
+		return Product{}, err
+	}
+	p = p.withCategoryID()
+	if err := p.Validate(m.categories); err != nil {
+		return Product{}, apperr.NewError(apperr.CodeValidation, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idx := m.current.Load()
+	if _, has := idx.byID[p.ID]; has {
+		return Product{}, apperr.NewError(apperr.CodeConstraint, fmt.Errorf("product %s already exists", p.ID))
+	}
+	if _, has := m.retired[p.ID]; has {
+		return Product{}, apperr.NewError(apperr.CodeConstraint, fmt.Errorf("product %s has been retired and its id can't be reused", p.ID))
+	}
+	m.current.Store(NewIndex(append(slices.Clone(idx.products), p)).replacing(idx, time.Now()))
+	return p, nil
+}
+
+// Update implements [WriteStore.Update].
+func (m *Mem) Update(ctx context.Context, id string, fn func(p Product) (Product, error)) (Product, error) {
+	if err := ctx.Err(); err != nil {
+		return Product{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idx := m.current.Load()
+	i, has := idx.byID[id]
+	if !has {
+		return Product{}, apperr.NewError(apperr.CodeNotFound, fmt.Errorf("product %s not found", id))
+	}
+	p, err := fn(idx.products[i])
+	if err != nil {
+		return Product{}, err
+	}
+	p.ID = id
+	p = p.withCategoryID()
+	if err := p.Validate(m.categories); err != nil {
+		return Product{}, apperr.NewError(apperr.CodeValidation, err)
+	}
+	ps := slices.Clone(idx.products)
+	ps[i] = p
+	m.current.Store(NewIndex(ps).replacing(idx, time.Now()))
+	return p, nil
+}
+
+// Retire implements [WriteStore.Retire].
+func (m *Mem) Retire(ctx context.Context, id string) (Product, error) {
+	if err := ctx.Err(); err != nil {
+		return Product{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idx := m.current.Load()
+	i, has := idx.byID[id]
+	if !has {
+		return Product{}, apperr.NewError(apperr.CodeNotFound, fmt.Errorf("product %s not found", id))
+	}
+	m.retired[id] = struct{}{}
+	m.current.Store(NewIndex(slices.Delete(slices.Clone(idx.products), i, i+1)).replacing(idx, time.Now()))
+	return idx.products[i], nil
+}
@@ -0,0 +1,165 @@
+package products
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMem(t *testing.T) {
+	t.Parallel()
+
+	// assertCode fails the test if err isn't an app error with code.
+	assertCode := func(t *testing.T, err error, code apperr.Code) {
+		t.Helper()
+		ae, ok := err.(apperr.Error)
+		require.True(t, ok, "err must be an app error")
+		assert.Equal(t, code, ae.Code)
+	}
+	newMem := func(t *testing.T) *Mem {
+		t.Helper()
+		m, err := NewMem(testProducts, testCategories)
+		require.NoError(t, err)
+		return m
+	}
+	lemon := Product{ID: "4", Name: "Lemon", Category: "Tart", CategoryID: "tart", Price: money.New(600, "AUD")}
+
+	t.Run("create", func(t *testing.T) {
+		t.Parallel()
+		m := newMem(t)
+		p, err := m.Create(t.Context(), lemon)
+		require.NoError(t, err)
+		assert.Equal(t, lemon, p)
+		ps, err := m.List(t.Context(), 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, append(testProducts[:len(testProducts):len(testProducts)], lemon), ps, "listed last")
+
+		_, err = m.Create(t.Context(), lemon)
+		assertCode(t, err, apperr.CodeConstraint)
+		assert.ErrorContains(t, err, "product 4 already exists")
+	})
+
+	t.Run("category IDs set", func(t *testing.T) {
+		t.Parallel()
+		m := newMem(t)
+		tart := lemon
+		tart.CategoryID = ""
+		p, err := m.Create(t.Context(), tart)
+		require.NoError(t, err)
+		assert.Equal(t, lemon, p)
+
+		p, err = m.Update(t.Context(), "1", func(p Product) (Product, error) {
+			p.Category = "Tart"
+			return p, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Tart", p.Category)
+		assert.Equal(t, "tart", p.CategoryID)
+	})
+
+	t.Run("create invalid", func(t *testing.T) {
+		t.Parallel()
+		_, err := newMem(t).Create(t.Context(), Product{ID: "4", Category: "Pie"})
+		assertCode(t, err, apperr.CodeValidation)
+		assert.ErrorContains(t, err, "name is required\nprice must be greater than zero\ncategory Pie not found")
+	})
+
+	t.Run("update", func(t *testing.T) {
+		t.Parallel()
+		m := newMem(t)
+		p, err := m.Update(t.Context(), "2", func(p Product) (Product, error) {
+			p.ID = "changed"
+			p.Price = money.New(900, "AUD")
+			return p, nil
+		})
+		require.NoError(t, err)
+		want := testProducts[1]
+		want.Price = money.New(900, "AUD")
+		assert.Equal(t, want, p, "id can't change")
+		got, err := m.Get(t.Context(), "2")
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+		ps, err := m.List(t.Context(), 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, want, ps[1], "keeps its place")
+	})
+
+	t.Run("update fails", func(t *testing.T) {
+		t.Parallel()
+		m := newMem(t)
+		_, err := m.Update(t.Context(), "9001", func(p Product) (Product, error) { return p, nil })
+		assertCode(t, err, apperr.CodeNotFound)
+
+		_, err = m.Update(t.Context(), "2", func(p Product) (Product, error) {
+			p.Name = ""
+			return p, nil
+		})
+		assertCode(t, err, apperr.CodeValidation)
+
+		_, err = m.Update(t.Context(), "2", func(p Product) (Product, error) { return p, errors.New("nope") })
+		assert.EqualError(t, err, "nope")
+
+		got, err := m.Get(t.Context(), "2")
+		require.NoError(t, err)
+		assert.Equal(t, testProducts[1], got, "unchanged")
+	})
+
+	t.Run("retire", func(t *testing.T) {
+		t.Parallel()
+		m := newMem(t)
+		p, err := m.Retire(t.Context(), "1")
+		require.NoError(t, err)
+		assert.Equal(t, testProducts[0], p)
+		_, err = m.Get(t.Context(), "1")
+		assertCode(t, err, apperr.CodeNotFound)
+		ps, err := m.List(t.Context(), 0, len(testProducts))
+		require.NoError(t, err)
+		assert.Len(t, ps, len(testProducts)-1)
+
+		_, err = m.Retire(t.Context(), "1")
+		assertCode(t, err, apperr.CodeNotFound)
+
+		_, err = m.Create(t.Context(), p)
+		assertCode(t, err, apperr.CodeConstraint)
+		assert.ErrorContains(t, err, "product 1 has been retired and its id can't be reused")
+	})
+
+	t.Run("version changes with the products", func(t *testing.T) {
+		t.Parallel()
+		m := newMem(t)
+		version := func() Version {
+			t.Helper()
+			v, err := m.Version(t.Context())
+			require.NoError(t, err)
+			return v
+		}
+		initial := version()
+		assert.False(t, initial.Modified.IsZero())
+
+		_, err := m.Update(t.Context(), "1", func(p Product) (Product, error) { return p, nil })
+		require.NoError(t, err)
+		assert.Equal(t, initial, version(), "unchanged product")
+		_, err = m.Update(t.Context(), "1", func(p Product) (Product, error) { return Product{}, nil })
+		require.Error(t, err)
+		assert.Equal(t, initial, version(), "failed update")
+
+		_, err = m.Create(t.Context(), lemon)
+		require.NoError(t, err)
+		created := version()
+		assert.NotEqual(t, initial.Hash, created.Hash)
+		assert.False(t, created.Modified.Before(initial.Modified))
+		_, err = m.Retire(t.Context(), lemon.ID)
+		require.NoError(t, err)
+		assert.Equal(t, initial.Hash, version().Hash, "same products as before")
+	})
+
+	t.Run("invalid catalogue", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewMem(testProducts, nil)
+		assert.ErrorContains(t, err, "product[0] category Cake not found")
+	})
+}
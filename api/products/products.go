@@ -2,12 +2,19 @@ package products
 
 import (
 	"context"
+	"crypto/sha256"
 	_ "embed"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/categories"
+	"github.com/matgreaves/kart-challenge/api/fold"
+	"github.com/matgreaves/kart-challenge/api/money"
 )
 
 //go:embed data.json
@@ -15,18 +22,92 @@ var SampleData []byte
 
 // Product defines model for Product.
 type Product struct {
-	Category string  `json:"category,omitempty"`
-	ID       string  `json:"id,omitempty"`
-	Name     string  `json:"name,omitempty"`
-	Price    float32 `json:"price,omitempty"`
+	// Category is the category the product is in as it's displayed, such as "Crème Brûlée".
+	Category string `json:"category,omitempty"`
+	// CategoryID is the ID of the category the product is in, see [categories.Category]. It's
+	// derived from Category whenever products are loaded or written.
+	CategoryID string      `json:"categoryId,omitempty"`
+	ID         string      `json:"id,omitempty"`
+	Name       string      `json:"name,omitempty"`
+	Price      money.Money `json:"price,omitzero"`
 	// note: demo server responses include an image field. Leaving off to match
 	// the OpenAPI spec but might be missing.
 }
 
+// Validate checks p has an ID, a name, a price and is in one of cs.
+func (p Product) Validate(cs []categories.Category) error {
+	if ve := p.problems(categoryIDs(cs)); len(ve) > 0 {
+		return errors.Join(ve...)
+	}
+	return nil
+}
+
+// problems lists everything wrong with p given the known category IDs.
+func (p Product) problems(known map[string]struct{}) []error {
+	ve := []error{}
+	if p.ID == "" {
+		ve = append(ve, errors.New("id is required"))
+	}
+	if p.Name == "" {
+		ve = append(ve, errors.New("name is required"))
+	}
+	if p.Price.Amount <= 0 {
+		ve = append(ve, errors.New("price must be greater than zero"))
+	}
+	if p.Category == "" {
+		ve = append(ve, errors.New("category is required"))
+	} else if _, has := known[p.CategoryID]; !has {
+		ve = append(ve, fmt.Errorf("category %s not found", p.Category))
+	}
+	return ve
+}
+
+// withCategoryID returns p with its CategoryID set from its Category, "Crème Brûlée" is in
+// creme-brulee.
+func (p Product) withCategoryID() Product {
+	p.CategoryID = fold.Slug(p.Category)
+	return p
+}
+
+func categoryIDs(cs []categories.Category) map[string]struct{} {
+	ids := make(map[string]struct{}, len(cs))
+	for _, v := range cs {
+		ids[v.ID] = struct{}{}
+	}
+	return ids
+}
+
 // Store contains the methods for interacting with a store of product data.
+//
+// Every method returns ctx.Err() once ctx is done. Implementations are checked with storetest.Products.
 type Store interface {
 	Get(ctx context.Context, id string) (Product, error)
 	List(ctx context.Context, page, pageSize int) ([]Product, error)
+	// Search returns the page of products matching q, or an [apperr.CodeValidation] error if q
+	// isn't valid.
+	Search(ctx context.Context, q Query) (Page, error)
+	// Version identifies the products currently in the store.
+	Version(ctx context.Context) (Version, error)
+}
+
+// Version identifies the products held by a [Store] at a point in time.
+type Version struct {
+	// Hash changes exactly when the products in the store, or the order they're listed in, change.
+	Hash string
+	// Modified is when the products last changed, zero when unknown. It only moves when Hash does.
+	Modified time.Time
+	// SameSecond is set when the products also changed earlier in the second of Modified, so
+	// Modified to the second, as sent in Last-Modified, doesn't identify this version.
+	SameSecond bool
+}
+
+// versionHash hashes everything about ps that's visible to clients.
+func versionHash(ps []Product) string {
+	h := sha256.New()
+	for _, v := range ps {
+		fmt.Fprintf(h, "%q %q %q %q %d\n", v.ID, v.Name, v.Category, v.Price.Currency, v.Price.Amount)
+	}
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 // NewSlice creates a [Slice] from r where b contains a JSON encoded
@@ -39,7 +120,7 @@ func NewSlice(b []byte) Slice {
 	if err := json.Unmarshal(b, &s); err != nil {
 		panic(err)
 	}
-	return s
+	return s.withCategoryIDs()
 }
 
 var _ Store = Slice{}
@@ -47,8 +128,21 @@ var _ Store = Slice{}
 // Slice is a [Store] backed by a static slice of [Product]. Useful for testing.
 type Slice []Product
 
+// withCategoryIDs returns a copy of s with the CategoryID of every product set, see
+// [Product.CategoryID].
+func (s Slice) withCategoryIDs() Slice {
+	ps := slices.Clone(s)
+	for i, v := range ps {
+		ps[i] = v.withCategoryID()
+	}
+	return ps
+}
+
 // Get implements [Store.Get].
-func (s Slice) Get(_ context.Context, id string) (Product, error) {
+func (s Slice) Get(ctx context.Context, id string) (Product, error) {
+	if err := ctx.Err(); err != nil {
+		return Product{}, err
+	}
 	for _, v := range s {
 		if v.ID == id {
 			return v, nil
@@ -58,18 +152,26 @@ func (s Slice) Get(_ context.Context, id string) (Product, error) {
 }
 
 // List implements [Store.List].
-func (s Slice) List(_ context.Context, page, pageSize int) ([]Product, error) {
-	if page < 0 {
-		return nil, apperr.Error{
-			Code:  apperr.CodeValidation,
-			Cause: errors.New("page must be zero or greater"),
-		}
+func (s Slice) List(ctx context.Context, page, pageSize int) ([]Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	if pageSize < 1 {
-		return nil, apperr.Error{
-			Code:  apperr.CodeValidation,
-			Cause: errors.New("pageSize must be greater than zero"),
-		}
+	if err := validatePage(page, pageSize); err != nil {
+		return nil, err
 	}
 	return s[min(pageSize*page, len(s)):min((pageSize*page)+pageSize, len(s))], nil
 }
+
+// Search implements [Store.Search].
+func (s Slice) Search(ctx context.Context, q Query) (Page, error) {
+	return search(ctx, s, q)
+}
+
+// Version implements [Store.Version]. A Slice doesn't know when it was last changed so the
+// version is never Modified.
+func (s Slice) Version(ctx context.Context) (Version, error) {
+	if err := ctx.Err(); err != nil {
+		return Version{}, err
+	}
+	return Version{Hash: versionHash(s)}, nil
+}
@@ -5,28 +5,32 @@ import (
 	"testing"
 
 	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/money"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 var testProducts = []Product{
 	{
-		ID:       "1",
-		Category: "Cake",
-		Name:     "Black Forest",
-		Price:    7.5,
+		ID:         "1",
+		Category:   "Cake",
+		CategoryID: "cake",
+		Name:       "Black Forest",
+		Price:      money.New(750, "AUD"),
 	},
 	{
-		ID:       "2",
-		Category: "Cake",
-		Name:     "Carrot",
-		Price:    8,
+		ID:         "2",
+		Category:   "Cake",
+		CategoryID: "cake",
+		Name:       "Carrot",
+		Price:      money.New(800, "AUD"),
 	},
 	{
-		ID:       "3",
-		Category: "Cake",
-		Name:     "Red Velvet",
-		Price:    5,
+		ID:         "3",
+		Category:   "Cake",
+		CategoryID: "cake",
+		Name:       "Red Velvet",
+		Price:      money.New(500, "AUD"),
 	},
 }
 
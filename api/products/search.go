@@ -0,0 +1,120 @@
+package products
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/fold"
+	"github.com/matgreaves/kart-challenge/api/money"
+)
+
+// Fields products can be sorted by with [Query.Sort].
+const (
+	SortName  = "name"
+	SortPrice = "price"
+)
+
+// Query filters, sorts and paginates the products returned by [Store.Search].
+type Query struct {
+	// Name matches products with a name containing it, ignoring case and accents.
+	Name string
+	// Category matches products with exactly this [Product.CategoryID].
+	Category string
+	// MinPrice and MaxPrice match products priced between them inclusive, zero for no limit.
+	MinPrice money.Money
+	MaxPrice money.Money
+	// Sort is the field to order products by, [SortName] or [SortPrice], prefixed with "-" to sort
+	// descending. Products that sort the same, or every product when empty, are kept in the order
+	// they're listed by [Store.List].
+	Sort string
+	// Page and PageSize select the page of matching products, as for [Store.List].
+	Page     int
+	PageSize int
+}
+
+// Validate checks q is a valid query.
+func (q Query) Validate() error {
+	ve := []error{}
+	if field := strings.TrimPrefix(q.Sort, "-"); q.Sort != "" && field != SortName && field != SortPrice {
+		ve = append(ve, fmt.Errorf("unknown sort field %q, must be one of %s or %s", field, SortName, SortPrice))
+	}
+	if !q.MinPrice.IsZero() && !q.MaxPrice.IsZero() {
+		if c, err := q.MinPrice.Cmp(q.MaxPrice); err != nil {
+			ve = append(ve, err)
+		} else if c > 0 {
+			ve = append(ve, errors.New("minPrice must not be more than maxPrice"))
+		}
+	}
+	if err := validatePage(q.Page, q.PageSize); err != nil {
+		ve = append(ve, err)
+	}
+	if len(ve) > 0 {
+		return apperr.NewError(apperr.CodeValidation, errors.Join(ve...))
+	}
+	return nil
+}
+
+// Page is a single page of the products matching a [Query].
+type Page struct {
+	Products []Product
+	// Total is the number of products matching the query across every page.
+	Total int
+}
+
+// search implements [Store.Search] over ps listed in order.
+func search(ctx context.Context, ps []Product, q Query) (Page, error) {
+	if err := ctx.Err(); err != nil {
+		return Page{}, err
+	}
+	if err := q.Validate(); err != nil {
+		return Page{}, err
+	}
+	name := fold.String(q.Name)
+	matched := []Product{}
+	for _, v := range ps {
+		if q.Category != "" && v.CategoryID != q.Category {
+			continue
+		}
+		if name != "" && !strings.Contains(fold.String(v.Name), name) {
+			continue
+		}
+		if !q.MinPrice.IsZero() {
+			// prices in another currency can't be compared so never match
+			if c, err := v.Price.Cmp(q.MinPrice); err != nil || c < 0 {
+				continue
+			}
+		}
+		if !q.MaxPrice.IsZero() {
+			if c, err := v.Price.Cmp(q.MaxPrice); err != nil || c > 0 {
+				continue
+			}
+		}
+		matched = append(matched, v)
+	}
+
+	if q.Sort != "" {
+		field, desc := strings.CutPrefix(q.Sort, "-")
+		slices.SortStableFunc(matched, func(a, b Product) int {
+			c := 0
+			if field == SortName {
+				c = cmp.Compare(fold.String(a.Name), fold.String(b.Name))
+			} else if c = cmp.Compare(a.Price.Currency, b.Price.Currency); c == 0 {
+				c = cmp.Compare(a.Price.Amount, b.Price.Amount)
+			}
+			if desc {
+				return -c
+			}
+			return c
+		})
+	}
+
+	return Page{
+		Products: matched[min(q.PageSize*q.Page, len(matched)):min((q.PageSize*q.Page)+q.PageSize, len(matched))],
+		Total:    len(matched),
+	}, nil
+}
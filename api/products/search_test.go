@@ -0,0 +1,29 @@
+package products
+
+import (
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery_Validate(t *testing.T) {
+	t.Parallel()
+	err := Query{
+		Sort:     "-colour",
+		MinPrice: money.New(800, "AUD"),
+		MaxPrice: money.New(500, "AUD"),
+		Page:     -1,
+		PageSize: 1,
+	}.Validate()
+	ae, ok := err.(apperr.Error)
+	require.True(t, ok, "err must be an app error")
+	assert.Equal(t, apperr.CodeValidation, ae.Code)
+	assert.ErrorContains(t, err, `unknown sort field "colour", must be one of name or price`)
+	assert.ErrorContains(t, err, "minPrice must not be more than maxPrice")
+	assert.ErrorContains(t, err, "page must be zero or greater")
+
+	assert.NoError(t, Query{Sort: "-price", MinPrice: money.New(500, "AUD"), PageSize: 1}.Validate())
+}
@@ -0,0 +1,54 @@
+package products_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/categories"
+	"github.com/matgreaves/kart-challenge/api/products"
+	"github.com/matgreaves/kart-challenge/api/storetest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreConformance(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Slice", func(t *testing.T) {
+		t.Parallel()
+		storetest.Products(t, func(t *testing.T, ps []products.Product) products.Store {
+			return products.Slice(ps)
+		})
+	})
+
+	t.Run("Index", func(t *testing.T) {
+		t.Parallel()
+		storetest.Products(t, func(t *testing.T, ps []products.Product) products.Store {
+			return products.NewIndex(ps)
+		})
+	})
+
+	t.Run("Mem", func(t *testing.T) {
+		t.Parallel()
+		storetest.Products(t, func(t *testing.T, ps []products.Product) products.Store {
+			m, err := products.NewMem(ps, []categories.Category{{ID: "cake", Name: "Cakes"}, {ID: "waffle", Name: "Waffles"}})
+			require.NoError(t, err)
+			return m
+		})
+	})
+
+	t.Run("File", func(t *testing.T) {
+		t.Parallel()
+		storetest.Products(t, func(t *testing.T, ps []products.Product) products.Store {
+			b, err := json.Marshal(ps)
+			require.NoError(t, err)
+			path := filepath.Join(t.TempDir(), "products.json")
+			require.NoError(t, os.WriteFile(path, b, 0o644))
+			f, err := products.OpenFile(path, []categories.Category{{ID: "cake", Name: "Cakes"}, {ID: "waffle", Name: "Waffles"}}, slog.New(slog.DiscardHandler))
+			require.NoError(t, err)
+			return f
+		})
+	})
+}
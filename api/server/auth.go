@@ -12,6 +12,8 @@ type tokenKey struct{}
 //
 // There are lots of alternatives with the defacto standard being the [JWT](https://datatracker.ietf.org/doc/html/rfc7519).
 type Token struct {
+	// Subject identifies the principal the token was issued to.
+	Subject   string
 	ValidFrom time.Time
 	ExpiresAt time.Time
 	Scopes    map[string]struct{}
@@ -31,6 +33,12 @@ func TokenFromContext(ctx context.Context) (_ Token, found bool) {
 	return v.(Token), true
 }
 
+// HasScope reports whether t grants scope.
+func (t Token) HasScope(scope string) bool {
+	_, has := t.Scopes[scope]
+	return has
+}
+
 // Validate checks whether the token should be accepted for further use.
 func (t Token) Validate(at time.Time) error {
 	if at.Before(t.ValidFrom) {
@@ -54,9 +62,12 @@ type StaticAuthProvider map[string]Token
 // never be used in a deployed application.
 func TestAuth() StaticAuthProvider {
 	return StaticAuthProvider{
-		"apitest":  Token{ExpiresAt: time.Now().AddDate(1, 0, 0), Scopes: map[string]struct{}{"order:create": {}}},
-		"noscope":  Token{ExpiresAt: time.Now().AddDate(1, 0, 0)},
-		"tooearly": Token{ValidFrom: time.Now().AddDate(1, 0, 0), ExpiresAt: time.Now().AddDate(1, 0, 0)},
-		"toolate":  Token{ValidFrom: time.Now().AddDate(-1, 0, 0), ExpiresAt: time.Now().AddDate(-1, 0, 0)},
+		"apitest":  Token{Subject: "test-client", ExpiresAt: time.Now().AddDate(1, 0, 0), Scopes: map[string]struct{}{"order:create": {}, "order:read": {}, "order:cancel": {}}},
+		"apitest2": Token{Subject: "test-client-2", ExpiresAt: time.Now().AddDate(1, 0, 0), Scopes: map[string]struct{}{"order:create": {}, "order:read": {}, "order:cancel": {}}},
+		"staff":    Token{Subject: "test-staff", ExpiresAt: time.Now().AddDate(1, 0, 0), Scopes: map[string]struct{}{"order:read": {}, "order:read:any": {}, "order:accept": {}, "order:prepare": {}, "order:ready": {}, "order:complete": {}, "order:reject": {}, "order:cancel": {}, "order:cancel:any": {}}},
+		"admin":    Token{Subject: "test-admin", ExpiresAt: time.Now().AddDate(1, 0, 0), Scopes: map[string]struct{}{"webhook:admin": {}, "stock:write": {}, "product:write": {}}},
+		"noscope":  Token{Subject: "test-noscope", ExpiresAt: time.Now().AddDate(1, 0, 0)},
+		"tooearly": Token{Subject: "test-tooearly", ValidFrom: time.Now().AddDate(1, 0, 0), ExpiresAt: time.Now().AddDate(1, 0, 0)},
+		"toolate":  Token{Subject: "test-toolate", ValidFrom: time.Now().AddDate(-1, 0, 0), ExpiresAt: time.Now().AddDate(-1, 0, 0)},
 	}
 }
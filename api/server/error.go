@@ -1,8 +1,11 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 
 	"github.com/matgreaves/kart-challenge/api/apperr"
@@ -69,3 +72,18 @@ func appErrToServer(err error) ServerError {
 
 	return serr
 }
+
+// writeErr logs err and writes it to w as a [ServerError], obscuring any error that isn't
+// meant to be seen by clients.
+func writeErr(ctx context.Context, s *slog.Logger, w http.ResponseWriter, err error) {
+	// log the original error before we possible obscure it as an iternal sever error.
+	s.ErrorContext(ctx, err.Error())
+	var se ServerError
+	if !errors.As(err, &se) {
+		se = appErrToServer(err)
+	}
+	w.WriteHeader(se.StatusCode())
+	if err := json.NewEncoder(w).Encode(se); err != nil {
+		s.ErrorContext(ctx, "failed to write error response: "+err.Error())
+	}
+}
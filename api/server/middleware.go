@@ -1,13 +1,31 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/matgreaves/kart-challenge/api/idempotency"
 )
 
-const APIKeyHeader = "api_key"
+const (
+	APIKeyHeader = "api_key"
+	// IdempotencyKeyHeader lets clients safely retry requests, see [IdempotentHandler].
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed by [IdempotentHandler].
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLen = 255
+	maxIdempotentBody    = 1 << 20 // 1MB
+)
 
 // LoggedHandler logs interesting request and response attributes whenever a request is
 // received or completed.
@@ -36,15 +54,29 @@ func LoggedHandler(s *slog.Logger, h http.Handler) http.Handler {
 	})
 }
 
+// CacheControlHandler sets the Cache-Control header of every response from next to value.
+func CacheControlHandler(value string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", value)
+		next.ServeHTTP(w, r)
+	})
+}
+
 // AuthenticatedHandler checks the incoming request for an authentication token rejecting
 // the request if not found or not valid. The auth token is then propagated for future use.
 //
-// except is a basic path prefix that lists routes that should be public and not require authentication
+// except is a basic path prefix that lists routes that should be public and not require authentication,
+// optionally preceded by a method, e.g. "GET /product". GET excepts also cover HEAD.
 func AuthenticatedHandler(p StaticAuthProvider, s *slog.Logger, next http.Handler, excepts ...string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		for _, except := range excepts {
+			method, prefix, hasMethod := strings.Cut(except, " ")
+			if !hasMethod {
+				method, prefix = "", except
+			}
+			methodMatches := method == "" || method == r.Method || (method == http.MethodGet && r.Method == http.MethodHead)
 			// skip auth if the route is excepted
-			if strings.HasPrefix(r.URL.Path, except) {
+			if methodMatches && strings.HasPrefix(r.URL.Path, prefix) {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -76,7 +108,7 @@ func ScopedHandler(s *slog.Logger, scope string, next http.Handler) http.Handler
 			w.WriteHeader(http.StatusForbidden)
 			return
 		}
-		if _, has = token.Scopes[scope]; !has {
+		if !token.HasScope(scope) {
 			s.Log(r.Context(), slog.LevelWarn, "token missing required scope: "+scope)
 			w.WriteHeader(http.StatusForbidden)
 			return
@@ -84,3 +116,118 @@ func ScopedHandler(s *slog.Logger, scope string, next http.Handler) http.Handler
 		next.ServeHTTP(w, r)
 	})
 }
+
+// IdempotentHandler replays the original response to requests that repeat an Idempotency-Key header
+// so clients can safely retry requests that have side effects. Keys are scoped to the [Token]
+// found in [r.Context()].
+//
+// Responses are only recorded if they aren't server errors, leaving the client free to retry.
+// Reusing a key with a different request body is rejected.
+func IdempotentHandler(s *slog.Logger, store idempotency.Store, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || store == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			writeErr(r.Context(), s, w, ServerError{
+				Code:    ErrCodeValidation,
+				Message: fmt.Sprintf("%s must be at most %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLen),
+			})
+			return
+		}
+
+		b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBody))
+		if err != nil {
+			writeErr(r.Context(), s, w, ServerError{
+				Code:    ErrCodeValidation,
+				Message: fmt.Sprintf("failed to read request payload: %s", err.Error()),
+			})
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		sum := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), b...))
+
+		token, _ := TokenFromContext(r.Context())
+		key = token.Subject + ":" + key
+		res, err := store.Begin(r.Context(), key, hex.EncodeToString(sum[:]))
+		if errors.Is(err, idempotency.ErrKeyReused) {
+			writeErr(r.Context(), s, w, ServerError{
+				Code:    ErrCodeConstraint,
+				Message: IdempotencyKeyHeader + " has already been used with a different request",
+			})
+			return
+		}
+		if err != nil {
+			writeErr(r.Context(), s, w, fmt.Errorf("failed to begin idempotent request: %w", err))
+			return
+		}
+		if res != nil {
+			s.Log(r.Context(), slog.LevelInfo, "replaying idempotent response")
+			for k, v := range res.Header {
+				w.Header()[k] = v
+			}
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.WriteHeader(res.StatusCode)
+			if _, err := w.Write(res.Body); err != nil {
+				s.Log(r.Context(), slog.LevelError, "failed to write replayed response: "+err.Error())
+			}
+			return
+		}
+
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			// release the key even if the request is cancelled or panics so the client can retry
+			if err := store.Abandon(context.WithoutCancel(r.Context()), key); err != nil {
+				s.Log(r.Context(), slog.LevelError, "failed to abandon idempotency key: "+err.Error())
+			}
+		}()
+
+		rec := &recordingWriter{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if rec.status >= http.StatusInternalServerError {
+			return
+		}
+		err = store.Complete(r.Context(), key, idempotency.Response{
+			StatusCode: rec.status,
+			Header:     w.Header().Clone(),
+			Body:       rec.body.Bytes(),
+		})
+		if err != nil {
+			s.Log(r.Context(), slog.LevelError, "failed to record idempotent response: "+err.Error())
+			return
+		}
+		completed = true
+	})
+}
+
+// recordingWriter is a [http.ResponseWriter] that keeps a copy of the response written to it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader implements [http.ResponseWriter.WriteHeader].
+func (rw *recordingWriter) WriteHeader(code int) {
+	if rw.status == 0 {
+		rw.status = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements [http.ResponseWriter.Write].
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/money"
+	"github.com/matgreaves/kart-challenge/api/products"
+)
+
+// productPatch changes only the fields of a product that are set.
+type productPatch struct {
+	Name     *string      `json:"name"`
+	Category *string      `json:"category"`
+	Price    *money.Money `json:"price"`
+}
+
+func (s Server) createProduct(ps products.WriteStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req products.Product
+		if !s.decodeProductReq(w, r, &req) {
+			return
+		}
+		p, err := ps.Create(r.Context(), req)
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+		s.auditProduct(r.Context(), "product created", p.ID, nil, &p)
+
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(p); err != nil {
+			s.Logger.ErrorContext(r.Context(), "failed to write createProduct response to client")
+		}
+	}
+}
+
+func (s Server) replaceProduct(ps products.WriteStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("productID")
+		var req products.Product
+		if !s.decodeProductReq(w, r, &req) {
+			return
+		}
+		if req.ID != "" && req.ID != id {
+			s.handleErr(r.Context(), w, apperr.NewError(apperr.CodeValidation, fmt.Errorf("id %s doesn't match product %s", req.ID, id)))
+			return
+		}
+		var before products.Product
+		p, err := ps.Update(r.Context(), id, func(p products.Product) (products.Product, error) {
+			before = p
+			return req, nil
+		})
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+		s.auditProduct(r.Context(), "product updated", id, &before, &p)
+
+		if err := json.NewEncoder(w).Encode(p); err != nil {
+			s.Logger.ErrorContext(r.Context(), "failed to write replaceProduct response to client")
+		}
+	}
+}
+
+func (s Server) patchProduct(ps products.WriteStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("productID")
+		var req productPatch
+		if !s.decodeProductReq(w, r, &req) {
+			return
+		}
+		if req.Name == nil && req.Category == nil && req.Price == nil {
+			s.handleErr(r.Context(), w, apperr.NewError(apperr.CodeValidation, errors.New("at least one of name, category or price is required")))
+			return
+		}
+		var before products.Product
+		p, err := ps.Update(r.Context(), id, func(p products.Product) (products.Product, error) {
+			before = p
+			if req.Name != nil {
+				p.Name = *req.Name
+			}
+			if req.Category != nil {
+				p.Category = *req.Category
+			}
+			if req.Price != nil {
+				p.Price = *req.Price
+			}
+			return p, nil
+		})
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+		s.auditProduct(r.Context(), "product updated", id, &before, &p)
+
+		if err := json.NewEncoder(w).Encode(p); err != nil {
+			s.Logger.ErrorContext(r.Context(), "failed to write patchProduct response to client")
+		}
+	}
+}
+
+func (s Server) retireProduct(ps products.WriteStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("productID")
+		p, err := ps.Retire(r.Context(), id)
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+		s.auditProduct(r.Context(), "product retired", id, &p, nil)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// decodeProductReq decodes the body of r into v rejecting unknown fields so mistyped fields
+// aren't silently dropped. Returns false when the request has already been failed.
+func (s Server) decodeProductReq(w http.ResponseWriter, r *http.Request, v any) bool {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(v); err != nil {
+		s.handleErr(r.Context(), w, ServerError{
+			Code:    ErrCodeBadRequest,
+			Message: fmt.Sprintf("invalid request payload: %s", err.Error()),
+		})
+		return false
+	}
+	return true
+}
+
+// auditProduct logs a change to the catalogue by the caller with the product before and after it,
+// before is nil for new products and after for retired ones.
+func (s Server) auditProduct(ctx context.Context, msg, id string, before, after *products.Product) {
+	token, _ := TokenFromContext(ctx)
+	attrs := []any{slog.String("productId", id), slog.String("by", token.Subject)}
+	if before != nil {
+		attrs = append(attrs, slog.Any("before", *before))
+	}
+	if after != nil {
+		attrs = append(attrs, slog.Any("after", *after))
+	}
+	s.Logger.InfoContext(ctx, msg, attrs...)
+}
@@ -3,31 +3,90 @@ package server
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/categories"
 	"github.com/matgreaves/kart-challenge/api/coupons"
+	"github.com/matgreaves/kart-challenge/api/fold"
+	"github.com/matgreaves/kart-challenge/api/idempotency"
+	"github.com/matgreaves/kart-challenge/api/inventory"
+	"github.com/matgreaves/kart-challenge/api/locations"
+	"github.com/matgreaves/kart-challenge/api/money"
 	"github.com/matgreaves/kart-challenge/api/orders"
 	"github.com/matgreaves/kart-challenge/api/products"
+	"github.com/matgreaves/kart-challenge/api/webhooks"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 const (
 	// Time given to inflight requests to complete before the server hard shuts down.
 	DefaultShutdownTimeout = 5 * time.Second
+	// Number of orders returned by GET /order when the client doesn't ask for a limit.
+	DefaultListLimit = 20
+	// Number of products returned by GET /product when the client doesn't ask for a pageSize.
+	DefaultPageSize = 100
+	// Largest pageSize a client can ask GET /product for.
+	MaxPageSize = 100
+
+	// Cache-Control of routes serving a catalogue. Clients can keep responses but must revalidate
+	// them, cheap with their ETag, so menu changes show straight away.
+	CatalogueCacheControl = "public, no-cache"
+	// Cache-Control of routes serving data that only changes on restart.
+	StaticCacheControl = "public, max-age=300"
+	// Cache-Control of routes serving a caller's own data.
+	PrivateCacheControl = "private, no-store"
 )
 
+// orderActions maps each action staff can take on an order to the [orders.Status] it moves the
+// order to. Each action is exposed as its own route guarded by its own scope.
+var orderActions = map[string]orders.Status{
+	"accept":   orders.StatusAccepted,
+	"prepare":  orders.StatusPreparing,
+	"ready":    orders.StatusReady,
+	"complete": orders.StatusCompleted,
+	"reject":   orders.StatusRejected,
+}
+
 type Server struct {
-	Addr     string
-	Auth     StaticAuthProvider
-	Logger   *slog.Logger
+	Addr   string
+	Auth   StaticAuthProvider
+	Logger *slog.Logger
+	// Products is the catalogue of the default location, served by the routes that don't name a
+	// location. When it's a [products.WriteStore] products can be managed through the API.
 	Products products.Store
-	Orders   orders.Store
-	Coupons  coupons.Store
+	// Locations has the catalogue of every location, when nil only the default location is served.
+	Locations locations.Store
+	// Categories are the categories products are grouped into, when nil categories aren't served.
+	Categories categories.Store
+	Orders     orders.Store
+	Coupons    coupons.Store
+	// Redemptions tracks uses of limited use coupons, when nil every coupon is unlimited.
+	Redemptions coupons.Redemptions
+	// OrderPolicy limits the orders that can be placed, when nil [orders.DefaultPolicy] is used.
+	OrderPolicy *orders.Policy
+	// Inventory tracks stock levels of products, when nil stock isn't tracked.
+	Inventory inventory.Store
+	// Idempotency tracks Idempotency-Key headers sent when placing orders, when nil the header
+	// is ignored.
+	Idempotency idempotency.Store
+	// Webhooks stores webhook subscriptions and the outbox of order events, when nil no events
+	// are published.
+	Webhooks webhooks.Store
+	// WebhookInterval is how often the outbox is checked for events to deliver, zero uses
+	// [webhooks.DefaultPollInterval].
+	WebhookInterval time.Duration
 }
 
 // Run starts s waiting for ctx to be cancelled before shutting down gracefully.
@@ -49,6 +108,24 @@ func (s Server) Run(ctx context.Context) error {
 		serr <- server.ListenAndServe()
 	}()
 
+	// deliver webhooks in the background for as long as the server runs
+	if s.Webhooks != nil {
+		dctx, stop := context.WithCancel(ctx)
+		dispatched := make(chan struct{})
+		go func() {
+			defer close(dispatched)
+			_ = webhooks.Dispatcher{
+				Store:        s.Webhooks,
+				Logger:       s.Logger,
+				PollInterval: s.WebhookInterval,
+			}.Run(dctx)
+		}()
+		defer func() {
+			stop()
+			<-dispatched
+		}()
+	}
+
 	s.Logger.InfoContext(ctx, "listening on "+s.Addr)
 
 	select {
@@ -71,42 +148,343 @@ func (s Server) Run(ctx context.Context) error {
 
 func (s Server) Handler() http.Handler {
 	m := &http.ServeMux{}
-	m.Handle("GET /product", s.listProducts())
-	m.Handle("GET /product/{productID}", s.getProduct())
-	m.Handle("POST /order", ScopedHandler(s.Logger, "order:create", s.createOrder()))
-	ah := AuthenticatedHandler(s.Auth, s.Logger, m, "/product")
+	m.Handle("GET /product", CacheControlHandler(CatalogueCacheControl, s.listProducts()))
+	m.Handle("GET /product/{productID}", CacheControlHandler(CatalogueCacheControl, s.getProduct()))
+	if ps, ok := s.Products.(products.WriteStore); ok {
+		m.Handle("POST /product", ScopedHandler(s.Logger, "product:write", s.createProduct(ps)))
+		m.Handle("PUT /product/{productID}", ScopedHandler(s.Logger, "product:write", s.replaceProduct(ps)))
+		m.Handle("PATCH /product/{productID}", ScopedHandler(s.Logger, "product:write", s.patchProduct(ps)))
+		m.Handle("DELETE /product/{productID}", ScopedHandler(s.Logger, "product:write", s.retireProduct(ps)))
+	}
+	if s.Locations != nil {
+		m.Handle("GET /location", CacheControlHandler(StaticCacheControl, s.listLocations()))
+		m.Handle("GET /location/{locationID}/product", CacheControlHandler(CatalogueCacheControl, s.listProducts()))
+		m.Handle("GET /location/{locationID}/product/{productID}", CacheControlHandler(CatalogueCacheControl, s.getProduct()))
+	}
+	if s.Categories != nil {
+		m.Handle("GET /category", CacheControlHandler(StaticCacheControl, s.listCategories()))
+		m.Handle("GET /category/{categoryID}/product", CacheControlHandler(CatalogueCacheControl, s.listProducts()))
+	}
+	if s.Inventory != nil {
+		m.Handle("PUT /product/{productID}/stock", ScopedHandler(s.Logger, "stock:write", s.setStock()))
+		if s.Locations != nil {
+			m.Handle("PUT /location/{locationID}/product/{productID}/stock", ScopedHandler(s.Logger, "stock:write", s.setStock()))
+		}
+	}
+	m.Handle("POST /order", ScopedHandler(s.Logger, "order:create", IdempotentHandler(s.Logger, s.Idempotency, s.createOrder())))
+	m.Handle("GET /order", CacheControlHandler(PrivateCacheControl, ScopedHandler(s.Logger, "order:read", s.listOrders())))
+	m.Handle("GET /order/{orderID}", CacheControlHandler(PrivateCacheControl, ScopedHandler(s.Logger, "order:read", s.getOrder())))
+	m.Handle("POST /order/{orderID}/cancel", ScopedHandler(s.Logger, "order:cancel", s.cancelOrder()))
+	for action, to := range orderActions {
+		m.Handle("POST /order/{orderID}/"+action, ScopedHandler(s.Logger, "order:"+action, s.transitionOrder(to)))
+	}
+	if s.Webhooks != nil {
+		m.Handle("POST /webhook", ScopedHandler(s.Logger, "webhook:admin", s.createWebhook()))
+		m.Handle("GET /webhook", ScopedHandler(s.Logger, "webhook:admin", s.listWebhooks()))
+		m.Handle("DELETE /webhook/{webhookID}", ScopedHandler(s.Logger, "webhook:admin", s.deleteWebhook()))
+		m.Handle("GET /webhook/deadletter", ScopedHandler(s.Logger, "webhook:admin", s.listDeadLetters()))
+	}
+	ah := AuthenticatedHandler(s.Auth, s.Logger, m, "GET /product", "GET /location", "GET /category")
 	return otelhttp.NewHandler(LoggedHandler(s.Logger, ah), "req")
 }
 
 func (s Server) listProducts() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// for now we don't support pagination in the API, set it to a reasonable default for "all"
-		p, err := s.Products.List(r.Context(), 0, 100)
+		ps, v, err := s.catalogue(r)
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+		q, err := productQuery(r)
 		if err != nil {
 			s.handleErr(r.Context(), w, err)
+			return
 		}
-		if err := json.NewEncoder(w).Encode(p); err != nil {
+		if id := r.PathValue("categoryID"); id != "" {
+			// the category can be named as it's displayed, the same as the category query
+			id = fold.Slug(id)
+			if _, err := s.Categories.Get(r.Context(), id); err != nil {
+				s.handleErr(r.Context(), w, err)
+				return
+			}
+			q.Category = id
+		}
+		if err := q.Validate(); err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+		if v.fresh(r) {
+			v.notModified(w)
+			return
+		}
+		p, err := ps.Search(r.Context(), q)
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+
+		v.set(w, r, ps)
+		w.Header().Set("X-Total-Count", strconv.Itoa(p.Total))
+		links := []string{}
+		if (q.Page+1)*q.PageSize < p.Total {
+			links = append(links, pageLink(r, q.Page+1, q.PageSize, "next"))
+		}
+		if q.Page > 0 {
+			// a page past the end links back to the last page rather than another empty one
+			last := max(p.Total-1, 0) / q.PageSize
+			links = append(links, pageLink(r, min(q.Page-1, last), q.PageSize, "prev"))
+		}
+		if len(links) > 0 {
+			w.Header().Set("Link", strings.Join(links, ", "))
+		}
+		if err := json.NewEncoder(w).Encode(p.Products); err != nil {
 			s.Logger.ErrorContext(r.Context(), "failed to write listProducts response to client")
 		}
 	}
 }
 
+// productQuery parses the query parameters of a listProducts request. The query itself is
+// validated once any category in the path has been added to it.
+func productQuery(r *http.Request) (products.Query, error) {
+	v := r.URL.Query()
+	q := products.Query{
+		Name: v.Get("name"),
+		// the category can be named as it's displayed, the same as in catalogues
+		Category: fold.Slug(v.Get("category")),
+		Sort:     v.Get("sort"),
+		PageSize: DefaultPageSize,
+	}
+	ve := []error{}
+	if p := v.Get("page"); p != "" {
+		var err error
+		if q.Page, err = strconv.Atoi(p); err != nil {
+			ve = append(ve, errors.New("page must be an integer"))
+		} else if q.Page < 0 {
+			ve = append(ve, errors.New("page must be zero or greater"))
+		}
+	}
+	if ps := v.Get("pageSize"); ps != "" {
+		var err error
+		if q.PageSize, err = strconv.Atoi(ps); err != nil {
+			ve = append(ve, errors.New("pageSize must be an integer"))
+		} else if q.PageSize < 1 || q.PageSize > MaxPageSize {
+			ve = append(ve, fmt.Errorf("pageSize must be between 1 and %d", MaxPageSize))
+		}
+	}
+	if q.Page > 0 && q.PageSize > 0 && q.Page >= math.MaxInt/q.PageSize {
+		// the Link to the next page would overflow
+		ve = append(ve, errors.New("page is too large"))
+	}
+	if p := v.Get("minPrice"); p != "" {
+		var err error
+		if q.MinPrice, err = money.Parse(p, money.DefaultCurrency); err != nil {
+			ve = append(ve, errors.New("minPrice must be a decimal amount"))
+		}
+	}
+	if p := v.Get("maxPrice"); p != "" {
+		var err error
+		if q.MaxPrice, err = money.Parse(p, money.DefaultCurrency); err != nil {
+			ve = append(ve, errors.New("maxPrice must be a decimal amount"))
+		}
+	}
+	if len(ve) > 0 {
+		return products.Query{}, apperr.NewError(apperr.CodeValidation, errors.Join(ve...))
+	}
+	return q, nil
+}
+
+// pageLink formats an RFC 8288 link to page of the listProducts request r.
+func pageLink(r *http.Request, page, pageSize int, rel string) string {
+	q := r.URL.Query()
+	q.Set("page", strconv.Itoa(page))
+	q.Set("pageSize", strconv.Itoa(pageSize))
+	u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+	return fmt.Sprintf("<%s>; rel=%q", u.String(), rel)
+}
+
 func (s Server) getProduct() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("productID")
-
-		p, err := s.Products.Get(r.Context(), id)
+		ps, v, err := s.catalogue(r)
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+		p, err := ps.Get(r.Context(), id)
 		if err != nil {
 			s.handleErr(r.Context(), w, err)
 			return
 		}
+		if v.fresh(r) {
+			v.notModified(w)
+			return
+		}
 
+		v.set(w, r, ps)
 		if err := json.NewEncoder(w).Encode(p); err != nil {
 			s.Logger.ErrorContext(r.Context(), "failed to write getProduct response to client")
 		}
 	}
 }
 
+func (s Server) listLocations() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ls, err := s.Locations.List(r.Context())
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(ls); err != nil {
+			s.Logger.ErrorContext(r.Context(), "failed to write listLocations response to client")
+		}
+	}
+}
+
+func (s Server) listCategories() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cs, err := s.Categories.List(r.Context())
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(cs); err != nil {
+			s.Logger.ErrorContext(r.Context(), "failed to write listCategories response to client")
+		}
+	}
+}
+
+// validators identify the version of a response so clients can revalidate their cached copy.
+type validators struct {
+	etag       string
+	modified   time.Time
+	sameSecond bool
+}
+
+// catalogueValidators returns the validators of the response to r built from the catalogue ps.
+// Responses differ by path and query so the ETag is unique to them as well as to the version of
+// the catalogue.
+func catalogueValidators(r *http.Request, ps products.Store) (validators, error) {
+	v, err := ps.Version(r.Context())
+	if err != nil {
+		return validators{}, err
+	}
+	h := sha256.Sum256([]byte(v.Hash + "\n" + r.URL.RequestURI()))
+	return validators{etag: `"` + hex.EncodeToString(h[:16]) + `"`, modified: v.Modified, sameSecond: v.SameSecond}, nil
+}
+
+// fresh reports whether the conditional request r is for a copy the client already has.
+// If-Modified-Since is only used when there's no If-None-Match, per RFC 9110, and never when the
+// catalogue changed more than once in the second it was last modified as the client's copy could
+// be from either change.
+func (v validators) fresh(r *http.Request) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			// If-None-Match uses the weak comparison
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == v.etag {
+				return true
+			}
+		}
+		return false
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !v.modified.IsZero() && !v.sameSecond && !v.modified.Truncate(time.Second).After(ims)
+}
+
+// set sets the validator headers of the response to r built from the catalogue ps. They're left
+// off when ps changed while the response was built as it could be from either version.
+func (v validators) set(w http.ResponseWriter, r *http.Request, ps products.Store) {
+	if now, err := catalogueValidators(r, ps); err != nil || now != v {
+		return
+	}
+	v.header(w)
+}
+
+// header sets the validator headers.
+func (v validators) header(w http.ResponseWriter) {
+	w.Header().Set("ETag", v.etag)
+	if !v.modified.IsZero() {
+		w.Header().Set("Last-Modified", v.modified.Format(http.TimeFormat))
+	}
+}
+
+// notModified tells the client their cached copy is still current.
+func (v validators) notModified(w http.ResponseWriter) {
+	v.header(w)
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// catalogue returns the products sold at the location named in the path of r, the default
+// location's products for routes without a location, along with the validators of the response
+// to r built from them.
+func (s Server) catalogue(r *http.Request) (products.Store, validators, error) {
+	ps, err := s.locationProducts(r)
+	if err != nil {
+		return nil, validators{}, err
+	}
+	v, err := catalogueValidators(r, ps)
+	if err != nil {
+		return nil, validators{}, err
+	}
+	return ps, v, nil
+}
+
+// locationProducts returns the products sold at the location named in the path of r, the default
+// location's products for routes without a location.
+func (s Server) locationProducts(r *http.Request) (products.Store, error) {
+	if id := r.PathValue("locationID"); id != "" {
+		return s.Locations.Products(r.Context(), id)
+	}
+	return s.Products, nil
+}
+
+// stockReq sets the stock level of a product.
+type stockReq struct {
+	Available int `json:"available"`
+	LowStock  int `json:"lowStock,omitempty"`
+}
+
+func (s Server) setStock() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("productID")
+		var req stockReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.handleErr(r.Context(), w, ServerError{
+				Code:    ErrCodeBadRequest,
+				Message: fmt.Sprintf("invalid request payload: %s", err.Error()),
+			})
+			return
+		}
+		ps, err := s.locationProducts(r)
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+		if _, err := ps.Get(r.Context(), id); err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+
+		l, err := s.Inventory.Set(r.Context(), inventory.Level{ProductID: id, Available: req.Available, LowStock: req.LowStock})
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+		token, _ := TokenFromContext(r.Context())
+		s.Logger.InfoContext(r.Context(), "product stock set",
+			slog.String("productId", id),
+			slog.Int("available", l.Available),
+			slog.String("by", token.Subject),
+		)
+
+		if err := json.NewEncoder(w).Encode(l); err != nil {
+			s.Logger.ErrorContext(r.Context(), "failed to write setStock response to client")
+		}
+	}
+}
+
 func (s Server) createOrder() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req orders.OrderReq
@@ -117,9 +495,11 @@ func (s Server) createOrder() http.HandlerFunc {
 			})
 			return
 		}
-		order, err := orders.Create(r.Context(), req, s.Orders, s.Products, s.Coupons)
+		token, _ := TokenFromContext(r.Context())
+		order, err := s.orderService().Create(r.Context(), token.Subject, req)
 		if err != nil {
 			s.handleErr(r.Context(), w, err)
+			return
 		}
 
 		if err := json.NewEncoder(w).Encode(order); err != nil {
@@ -128,16 +508,162 @@ func (s Server) createOrder() http.HandlerFunc {
 	}
 }
 
-// handleErr implements standard route error handling including logging and obfuscation.
-func (s Server) handleErr(ctx context.Context, w http.ResponseWriter, err error) {
-	// log the original error before we possible obscure it as an iternal sever error.
-	s.Logger.ErrorContext(ctx, err.Error())
-	var se ServerError
-	if !errors.As(err, &se) {
-		se = appErrToServer(err)
+func (s Server) listOrders() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := listQuery(r)
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+		// callers can only ever see the orders they created themselves
+		token, _ := TokenFromContext(r.Context())
+		q.CreatedBy = token.Subject
+
+		p, err := s.Orders.List(r.Context(), q)
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(p); err != nil {
+			s.Logger.ErrorContext(r.Context(), "failed to write listOrders response to client")
+		}
+	}
+}
+
+// listQuery parses the query parameters of a listOrders request.
+func listQuery(r *http.Request) (orders.ListQuery, error) {
+	v := r.URL.Query()
+	q := orders.ListQuery{
+		Cursor: v.Get("cursor"),
+		Limit:  DefaultListLimit,
+	}
+	ve := []error{}
+	if l := v.Get("limit"); l != "" {
+		var err error
+		if q.Limit, err = strconv.Atoi(l); err != nil {
+			ve = append(ve, errors.New("limit must be an integer"))
+		}
+	}
+	if f := v.Get("createdFrom"); f != "" {
+		var err error
+		if q.CreatedFrom, err = time.Parse(time.RFC3339, f); err != nil {
+			ve = append(ve, errors.New("createdFrom must be an RFC 3339 timestamp"))
+		}
+	}
+	if t := v.Get("createdTo"); t != "" {
+		var err error
+		if q.CreatedTo, err = time.Parse(time.RFC3339, t); err != nil {
+			ve = append(ve, errors.New("createdTo must be an RFC 3339 timestamp"))
+		}
+	}
+	for _, st := range v["status"] {
+		for _, s := range strings.Split(st, ",") {
+			q.Statuses = append(q.Statuses, orders.Status(s))
+		}
 	}
-	w.WriteHeader(se.StatusCode())
-	if err := json.NewEncoder(w).Encode(se); err != nil {
-		s.Logger.ErrorContext(ctx, "failed to write error response: "+err.Error())
+	if len(ve) > 0 {
+		return orders.ListQuery{}, apperr.NewError(apperr.CodeValidation, errors.Join(ve...))
 	}
+	return q, nil
+}
+
+func (s Server) getOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("orderID")
+
+		o, err := s.Orders.Get(r.Context(), id)
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+		// customers can only read their own orders, staff with order:read:any can read any order.
+		// Other customers' orders are reported missing so their IDs can't be probed.
+		token, _ := TokenFromContext(r.Context())
+		if o.CreatedBy != token.Subject && !token.HasScope("order:read:any") {
+			s.handleErr(r.Context(), w, apperr.NewError(apperr.CodeNotFound, fmt.Errorf("order %s not found", id)))
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(o); err != nil {
+			s.Logger.ErrorContext(r.Context(), "failed to write getOrder response to client")
+		}
+	}
+}
+
+func (s Server) transitionOrder(to orders.Status) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("orderID")
+		token, _ := TokenFromContext(r.Context())
+
+		o, err := s.orderService().Transition(r.Context(), id, to, token.Subject)
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+		s.Logger.InfoContext(r.Context(), "order status changed", slog.String("orderId", o.ID), slog.String("status", string(o.Status)))
+
+		if err := json.NewEncoder(w).Encode(o); err != nil {
+			s.Logger.ErrorContext(r.Context(), "failed to write transitionOrder response to client")
+		}
+	}
+}
+
+func (s Server) cancelOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("orderID")
+		var req orders.CancelReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.handleErr(r.Context(), w, ServerError{
+				Code:    ErrCodeBadRequest,
+				Message: fmt.Sprintf("invalid request payload: %s", err.Error()),
+			})
+			return
+		}
+
+		// customers can only cancel their own orders, staff can cancel any order
+		token, _ := TokenFromContext(r.Context())
+		if !token.HasScope("order:cancel:any") {
+			o, err := s.Orders.Get(r.Context(), id)
+			if err != nil {
+				s.handleErr(r.Context(), w, err)
+				return
+			}
+			if o.CreatedBy != token.Subject {
+				s.handleErr(r.Context(), w, apperr.NewError(apperr.CodeNotFound, fmt.Errorf("order %s not found", id)))
+				return
+			}
+		}
+
+		o, err := s.orderService().Cancel(r.Context(), id, req, token.Subject)
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+		s.Logger.InfoContext(r.Context(), "order cancelled", slog.String("orderId", o.ID), slog.String("reason", string(req.Reason)))
+
+		if err := json.NewEncoder(w).Encode(o); err != nil {
+			s.Logger.ErrorContext(r.Context(), "failed to write cancelOrder response to client")
+		}
+	}
+}
+
+// orderService returns the [orders.Service] backed by the stores of s.
+func (s Server) orderService() orders.Service {
+	return orders.Service{
+		Orders:      s.Orders,
+		Products:    s.Products,
+		Locations:   s.Locations,
+		Coupons:     s.Coupons,
+		Redemptions: s.Redemptions,
+		Inventory:   s.Inventory,
+		Policy:      s.OrderPolicy,
+		Events:      s.Webhooks,
+		Logger:      s.Logger,
+	}
+}
+
+// handleErr implements standard route error handling including logging and obfuscation.
+func (s Server) handleErr(ctx context.Context, w http.ResponseWriter, err error) {
+	writeErr(ctx, s.Logger, w, err)
 }
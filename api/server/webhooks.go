@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/matgreaves/kart-challenge/api/orders"
+	"github.com/matgreaves/kart-challenge/api/webhooks"
+)
+
+// webhookReq registers a new webhook subscription.
+type webhookReq struct {
+	URL    string             `json:"url"`
+	Events []orders.EventType `json:"events,omitempty"`
+	// Secret to sign deliveries with, one is generated when empty.
+	Secret string `json:"secret,omitempty"`
+}
+
+func (s Server) createWebhook() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req webhookReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.handleErr(r.Context(), w, ServerError{
+				Code:    ErrCodeBadRequest,
+				Message: fmt.Sprintf("invalid request payload: %s", err.Error()),
+			})
+			return
+		}
+		sub, err := s.Webhooks.CreateSubscription(r.Context(), webhooks.Subscription{
+			URL:    req.URL,
+			Events: req.Events,
+			Secret: req.Secret,
+		})
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+		s.Logger.InfoContext(r.Context(), "webhook created", slog.String("webhookId", sub.ID), slog.String("url", sub.URL))
+
+		// the only time the secret is ever returned
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(sub); err != nil {
+			s.Logger.ErrorContext(r.Context(), "failed to write createWebhook response to client")
+		}
+	}
+}
+
+func (s Server) listWebhooks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subs, err := s.Webhooks.ListSubscriptions(r.Context())
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+		for i := range subs {
+			subs[i].Secret = ""
+		}
+
+		if err := json.NewEncoder(w).Encode(subs); err != nil {
+			s.Logger.ErrorContext(r.Context(), "failed to write listWebhooks response to client")
+		}
+	}
+}
+
+func (s Server) deleteWebhook() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("webhookID")
+		if err := s.Webhooks.DeleteSubscription(r.Context(), id); err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+		s.Logger.InfoContext(r.Context(), "webhook deleted", slog.String("webhookId", id))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s Server) listDeadLetters() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dead, err := s.Webhooks.DeadLetters(r.Context())
+		if err != nil {
+			s.handleErr(r.Context(), w, err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(dead); err != nil {
+			s.Logger.ErrorContext(r.Context(), "failed to write listDeadLetters response to client")
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package storetest
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/coupons"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Coupons checks a [coupons.Store] implementation. newStore must return a new store holding
+// exactly codes, each with the [coupons.Default] rule.
+func Coupons(t *testing.T, newStore func(t *testing.T, codes []string) coupons.Store) {
+	t.Helper()
+	codes := []string{"HAPPYHRS", "FIFTYOFF", "BIRTHDAY", "A"}
+
+	t.Run("Lookup", func(t *testing.T) {
+		t.Parallel()
+
+		t.Run("has coupon", func(t *testing.T) {
+			t.Parallel()
+			s := newStore(t, codes)
+			for _, v := range codes {
+				c, err := s.Lookup(t.Context(), v)
+				require.NoError(t, err)
+				assert.Equal(t, coupons.Default(v), c)
+			}
+		})
+
+		t.Run("no coupon", func(t *testing.T) {
+			t.Parallel()
+			_, err := newStore(t, codes).Lookup(t.Context(), "NOPE")
+			assertCode(t, err, apperr.CodeNotFound)
+			assert.ErrorContains(t, err, "coupon NOPE not found")
+		})
+
+		t.Run("prefix and extension of a code", func(t *testing.T) {
+			t.Parallel()
+			s := newStore(t, codes)
+			for _, v := range []string{"HAPPY", "HAPPYHRSX", "happyhrs", "AA"} {
+				_, err := s.Lookup(t.Context(), v)
+				assertCode(t, err, apperr.CodeNotFound)
+			}
+		})
+
+		t.Run("empty code", func(t *testing.T) {
+			t.Parallel()
+			_, err := newStore(t, codes).Lookup(t.Context(), "")
+			assertCode(t, err, apperr.CodeNotFound)
+		})
+
+		t.Run("empty store", func(t *testing.T) {
+			t.Parallel()
+			_, err := newStore(t, nil).Lookup(t.Context(), codes[0])
+			assertCode(t, err, apperr.CodeNotFound)
+		})
+	})
+
+	t.Run("concurrent use", func(t *testing.T) {
+		t.Parallel()
+		s := newStore(t, codes)
+		wg := sync.WaitGroup{}
+		for i := range concurrency {
+			wg.Go(func() {
+				code := codes[i%len(codes)]
+				c, err := s.Lookup(t.Context(), code)
+				assert.NoError(t, err)
+				assert.Equal(t, code, c.Code)
+			})
+		}
+		wg.Wait()
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		t.Parallel()
+		_, err := newStore(t, codes).Lookup(cancelled(t), codes[0])
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,321 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/orders"
+	"github.com/matgreaves/kart-challenge/api/txn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Orders checks an [orders.Store] implementation. newStore must return a new, empty store.
+func Orders(t *testing.T, newStore func(t *testing.T) orders.Store) {
+	t.Helper()
+	// order returns an order with a single item of quantity created by createdBy.
+	order := func(quantity int, createdBy string) orders.Order {
+		return orders.Order{
+			Items:     []orders.OrderItem{{ProductID: "1", Quantity: quantity}},
+			Status:    orders.StatusPlaced,
+			CreatedBy: createdBy,
+		}
+	}
+	// seed creates n orders a second apart, the last two at the same time, returned in list order.
+	seed := func(t *testing.T, s orders.Store, n int) []orders.Order {
+		t.Helper()
+		at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		created := make([]orders.Order, 0, n)
+		for i := range n {
+			o := order(1, fmt.Sprintf("client-%d", i%2))
+			o.ID = fmt.Sprintf("order-%03d", i)
+			o.CreatedAt = at.Add(time.Duration(min(i, n-2)) * time.Second)
+			o, err := s.Create(t.Context(), o)
+			require.NoError(t, err)
+			created = append(created, o)
+		}
+		return created
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		t.Parallel()
+
+		t.Run("assigns id and creation time", func(t *testing.T) {
+			t.Parallel()
+			o, err := newStore(t).Create(t.Context(), order(1, "a"))
+			require.NoError(t, err)
+			assert.NotEmpty(t, o.ID)
+			assert.False(t, o.CreatedAt.IsZero())
+		})
+
+		t.Run("keeps id and creation time", func(t *testing.T) {
+			t.Parallel()
+			want := order(1, "a")
+			want.ID = "given"
+			want.CreatedAt = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			got, err := newStore(t).Create(t.Context(), want)
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+
+		t.Run("duplicate id", func(t *testing.T) {
+			t.Parallel()
+			s := newStore(t)
+			o, err := s.Create(t.Context(), order(1, "a"))
+			require.NoError(t, err)
+
+			dup := order(2, "b")
+			dup.ID = o.ID
+			_, err = s.Create(t.Context(), dup)
+			assertCode(t, err, apperr.CodeConstraint)
+
+			stored, err := s.Get(t.Context(), o.ID)
+			require.NoError(t, err)
+			assert.Equal(t, o, stored)
+		})
+	})
+
+	t.Run("Create rolled back", func(t *testing.T) {
+		t.Parallel()
+		s := newStore(t)
+		kept, err := s.Create(t.Context(), order(1, "a"))
+		require.NoError(t, err)
+
+		ctx, tx := txn.Begin(t.Context())
+		o, err := s.Create(ctx, order(2, "b"))
+		require.NoError(t, err)
+		require.NoError(t, tx.Rollback(ctx))
+
+		_, err = s.Get(t.Context(), o.ID)
+		assertCode(t, err, apperr.CodeNotFound)
+		p, err := s.List(t.Context(), orders.ListQuery{Limit: orders.MaxListLimit})
+		require.NoError(t, err)
+		assert.Equal(t, []orders.Order{kept}, p.Orders)
+
+		// the ID is free to use again
+		_, err = s.Create(t.Context(), o)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		t.Parallel()
+
+		t.Run("has order", func(t *testing.T) {
+			t.Parallel()
+			s := newStore(t)
+			o, err := s.Create(t.Context(), order(1, "a"))
+			require.NoError(t, err)
+			got, err := s.Get(t.Context(), o.ID)
+			require.NoError(t, err)
+			assert.Equal(t, o, got)
+		})
+
+		t.Run("no order", func(t *testing.T) {
+			t.Parallel()
+			_, err := newStore(t).Get(t.Context(), "missing")
+			assertCode(t, err, apperr.CodeNotFound)
+			assert.ErrorContains(t, err, "order missing not found")
+		})
+	})
+
+	t.Run("List", func(t *testing.T) {
+		t.Parallel()
+
+		t.Run("empty store", func(t *testing.T) {
+			t.Parallel()
+			p, err := newStore(t).List(t.Context(), orders.ListQuery{Limit: 1})
+			require.NoError(t, err)
+			assert.Empty(t, p.Orders)
+			assert.Empty(t, p.NextCursor)
+		})
+
+		t.Run("pages through every order in order", func(t *testing.T) {
+			t.Parallel()
+			s := newStore(t)
+			all := seed(t, s, 5)
+
+			got := []orders.Order{}
+			q := orders.ListQuery{Limit: 2}
+			for range len(all) {
+				p, err := s.List(t.Context(), q)
+				require.NoError(t, err)
+				require.LessOrEqual(t, len(p.Orders), q.Limit)
+				got = append(got, p.Orders...)
+				if p.NextCursor == "" {
+					break
+				}
+				q.Cursor = p.NextCursor
+			}
+			assert.Equal(t, all, got)
+		})
+
+		t.Run("limit > len(orders)", func(t *testing.T) {
+			t.Parallel()
+			s := newStore(t)
+			all := seed(t, s, 3)
+			p, err := s.List(t.Context(), orders.ListQuery{Limit: orders.MaxListLimit})
+			require.NoError(t, err)
+			assert.Equal(t, all, p.Orders)
+			assert.Empty(t, p.NextCursor)
+		})
+
+		t.Run("filters", func(t *testing.T) {
+			t.Parallel()
+			s := newStore(t)
+			all := seed(t, s, 4)
+			_, err := s.Update(t.Context(), all[0].ID, func(o orders.Order) (orders.Order, error) {
+				o.Status = orders.StatusCancelled
+				return o, nil
+			})
+			require.NoError(t, err)
+
+			p, err := s.List(t.Context(), orders.ListQuery{CreatedBy: "client-1", Limit: orders.MaxListLimit})
+			require.NoError(t, err)
+			assert.Equal(t, []orders.Order{all[1], all[3]}, p.Orders)
+
+			p, err = s.List(t.Context(), orders.ListQuery{CreatedFrom: all[1].CreatedAt, CreatedTo: all[2].CreatedAt, Limit: orders.MaxListLimit})
+			require.NoError(t, err)
+			assert.Equal(t, all[1:2], p.Orders)
+
+			p, err = s.List(t.Context(), orders.ListQuery{Statuses: []orders.Status{orders.StatusPlaced}, Limit: orders.MaxListLimit})
+			require.NoError(t, err)
+			assert.Equal(t, all[1:], p.Orders)
+		})
+
+		t.Run("invalid query", func(t *testing.T) {
+			t.Parallel()
+			_, err := newStore(t).List(t.Context(), orders.ListQuery{Cursor: "!!"})
+			assertCode(t, err, apperr.CodeValidation)
+		})
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		t.Parallel()
+
+		t.Run("updates order", func(t *testing.T) {
+			t.Parallel()
+			s := newStore(t)
+			o, err := s.Create(t.Context(), order(1, "a"))
+			require.NoError(t, err)
+
+			got, err := s.Update(t.Context(), o.ID, func(o orders.Order) (orders.Order, error) {
+				o.Status = orders.StatusAccepted
+				o.ID = "changed"
+				o.CreatedAt = o.CreatedAt.Add(time.Hour)
+				return o, nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, o.ID, got.ID)
+			assert.Equal(t, o.CreatedAt, got.CreatedAt)
+			assert.Equal(t, orders.StatusAccepted, got.Status)
+
+			stored, err := s.Get(t.Context(), o.ID)
+			require.NoError(t, err)
+			assert.Equal(t, got, stored)
+		})
+
+		t.Run("fn fails", func(t *testing.T) {
+			t.Parallel()
+			s := newStore(t)
+			o, err := s.Create(t.Context(), order(1, "a"))
+			require.NoError(t, err)
+
+			nope := errors.New("nope")
+			_, err = s.Update(t.Context(), o.ID, func(o orders.Order) (orders.Order, error) {
+				o.Status = orders.StatusAccepted
+				return o, nope
+			})
+			assert.ErrorIs(t, err, nope)
+
+			stored, err := s.Get(t.Context(), o.ID)
+			require.NoError(t, err)
+			assert.Equal(t, o, stored)
+		})
+
+		t.Run("rolled back", func(t *testing.T) {
+			t.Parallel()
+			s := newStore(t)
+			o, err := s.Create(t.Context(), order(1, "a"))
+			require.NoError(t, err)
+
+			ctx, tx := txn.Begin(t.Context())
+			_, err = s.Update(ctx, o.ID, func(o orders.Order) (orders.Order, error) {
+				o.Status = orders.StatusAccepted
+				return o, nil
+			})
+			require.NoError(t, err)
+			require.NoError(t, tx.Rollback(ctx))
+
+			stored, err := s.Get(t.Context(), o.ID)
+			require.NoError(t, err)
+			assert.Equal(t, o, stored)
+		})
+
+		t.Run("no order", func(t *testing.T) {
+			t.Parallel()
+			_, err := newStore(t).Update(t.Context(), "missing", func(o orders.Order) (orders.Order, error) { return o, nil })
+			assertCode(t, err, apperr.CodeNotFound)
+		})
+	})
+
+	t.Run("concurrent use", func(t *testing.T) {
+		t.Parallel()
+		s := newStore(t)
+		o, err := s.Create(t.Context(), order(0, "a"))
+		require.NoError(t, err)
+
+		wg := sync.WaitGroup{}
+		for range concurrency {
+			wg.Go(func() {
+				_, err := s.Create(t.Context(), order(1, "b"))
+				assert.NoError(t, err)
+				_, err = s.Update(t.Context(), o.ID, func(o orders.Order) (orders.Order, error) {
+					o.Items = []orders.OrderItem{{ProductID: o.Items[0].ProductID, Quantity: o.Items[0].Quantity + 1}}
+					return o, nil
+				})
+				assert.NoError(t, err)
+				_, err = s.List(t.Context(), orders.ListQuery{Limit: orders.MaxListLimit})
+				assert.NoError(t, err)
+			})
+		}
+		wg.Wait()
+
+		// every update must see the result of the one before it
+		got, err := s.Get(t.Context(), o.ID)
+		require.NoError(t, err)
+		assert.Equal(t, concurrency, got.Items[0].Quantity)
+		p, err := s.List(t.Context(), orders.ListQuery{Limit: orders.MaxListLimit})
+		require.NoError(t, err)
+		assert.Len(t, p.Orders, concurrency+1)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		t.Parallel()
+		s := newStore(t)
+		o, err := s.Create(t.Context(), order(1, "a"))
+		require.NoError(t, err)
+		ctx := cancelled(t)
+
+		_, err = s.Create(ctx, order(1, "b"))
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = s.Get(ctx, o.ID)
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = s.List(ctx, orders.ListQuery{Limit: 1})
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = s.Update(ctx, o.ID, func(o orders.Order) (orders.Order, error) {
+			o.Status = orders.StatusAccepted
+			return o, nil
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+
+		// nothing was written
+		p, err := s.List(t.Context(), orders.ListQuery{Limit: orders.MaxListLimit})
+		require.NoError(t, err)
+		assert.Equal(t, []orders.Order{o}, p.Orders)
+	})
+}
@@ -0,0 +1,233 @@
+package storetest
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/money"
+	"github.com/matgreaves/kart-challenge/api/products"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Products checks a [products.Store] implementation. newStore must return a new store holding
+// exactly ps, listed in the order given.
+func Products(t *testing.T, newStore func(t *testing.T, ps []products.Product) products.Store) {
+	t.Helper()
+	ps := []products.Product{
+		{ID: "1", Category: "Cake", CategoryID: "cake", Name: "Black Forest", Price: money.New(750, "AUD")},
+		{ID: "2", Category: "Cake", CategoryID: "cake", Name: "Carrot", Price: money.New(800, "AUD")},
+		{ID: "3", Category: "Waffle", CategoryID: "waffle", Name: "Red Velvet", Price: money.New(500, "AUD")},
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		t.Parallel()
+
+		t.Run("has product", func(t *testing.T) {
+			t.Parallel()
+			s := newStore(t, ps)
+			for _, want := range ps {
+				got, err := s.Get(t.Context(), want.ID)
+				require.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+		})
+
+		t.Run("no product", func(t *testing.T) {
+			t.Parallel()
+			_, err := newStore(t, ps).Get(t.Context(), "9001")
+			assertCode(t, err, apperr.CodeNotFound)
+			assert.ErrorContains(t, err, "product 9001 not found")
+		})
+
+		t.Run("empty id", func(t *testing.T) {
+			t.Parallel()
+			_, err := newStore(t, ps).Get(t.Context(), "")
+			assertCode(t, err, apperr.CodeNotFound)
+		})
+	})
+
+	t.Run("List", func(t *testing.T) {
+		t.Parallel()
+
+		t.Run("initial page", func(t *testing.T) {
+			t.Parallel()
+			got, err := newStore(t, ps).List(t.Context(), 0, 1)
+			require.NoError(t, err)
+			assert.Equal(t, ps[0:1], got)
+		})
+
+		t.Run("subsequent page", func(t *testing.T) {
+			t.Parallel()
+			got, err := newStore(t, ps).List(t.Context(), 1, 1)
+			require.NoError(t, err)
+			assert.Equal(t, ps[1:2], got)
+		})
+
+		t.Run("partial final page", func(t *testing.T) {
+			t.Parallel()
+			got, err := newStore(t, ps).List(t.Context(), 1, 2)
+			require.NoError(t, err)
+			assert.Equal(t, ps[2:], got)
+		})
+
+		t.Run("pageSize > len(items)", func(t *testing.T) {
+			t.Parallel()
+			got, err := newStore(t, ps).List(t.Context(), 0, len(ps)+1)
+			require.NoError(t, err)
+			assert.Equal(t, ps, got)
+		})
+
+		t.Run("page and pageSize > len(items)", func(t *testing.T) {
+			t.Parallel()
+			got, err := newStore(t, ps).List(t.Context(), len(ps)+1, len(ps)+1)
+			require.NoError(t, err)
+			assert.Empty(t, got)
+		})
+
+		t.Run("negative page", func(t *testing.T) {
+			t.Parallel()
+			_, err := newStore(t, ps).List(t.Context(), -1, 1)
+			assertCode(t, err, apperr.CodeValidation)
+		})
+
+		t.Run("huge page", func(t *testing.T) {
+			t.Parallel()
+			_, err := newStore(t, ps).List(t.Context(), math.MaxInt/2, 2)
+			assertCode(t, err, apperr.CodeValidation)
+			_, err = newStore(t, ps).Search(t.Context(), products.Query{Page: math.MaxInt / 2, PageSize: 2})
+			assertCode(t, err, apperr.CodeValidation)
+		})
+
+		t.Run("pageSize < 1", func(t *testing.T) {
+			t.Parallel()
+			_, err := newStore(t, ps).List(t.Context(), 0, 0)
+			assertCode(t, err, apperr.CodeValidation)
+		})
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		t.Parallel()
+		all := products.Query{PageSize: len(ps)}
+
+		for name, tc := range map[string]struct {
+			query func(q products.Query) products.Query
+			want  []products.Product
+		}{
+			"everything": {
+				query: func(q products.Query) products.Query { return q },
+				want:  ps,
+			},
+			"name ignores case and accents": {
+				query: func(q products.Query) products.Query { q.Name = "CARRÓT"; return q },
+				want:  ps[1:2],
+			},
+			"name substring": {
+				query: func(q products.Query) products.Query { q.Name = "velv"; return q },
+				want:  ps[2:],
+			},
+			"category": {
+				query: func(q products.Query) products.Query { q.Category = "cake"; return q },
+				want:  ps[:2],
+			},
+			"price range inclusive": {
+				query: func(q products.Query) products.Query {
+					q.MinPrice, q.MaxPrice = money.New(500, "AUD"), money.New(750, "AUD")
+					return q
+				},
+				want: []products.Product{ps[0], ps[2]},
+			},
+			"sort by price": {
+				query: func(q products.Query) products.Query { q.Sort = products.SortPrice; return q },
+				want:  []products.Product{ps[2], ps[0], ps[1]},
+			},
+			"sort by name descending": {
+				query: func(q products.Query) products.Query { q.Sort = "-" + products.SortName; return q },
+				want:  []products.Product{ps[2], ps[1], ps[0]},
+			},
+			"no match": {
+				query: func(q products.Query) products.Query { q.Name = "pavlova"; return q },
+				want:  []products.Product{},
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				got, err := newStore(t, ps).Search(t.Context(), tc.query(all))
+				require.NoError(t, err)
+				assert.Equal(t, tc.want, got.Products)
+				assert.Equal(t, len(tc.want), got.Total)
+			})
+		}
+
+		t.Run("page of sorted matches", func(t *testing.T) {
+			t.Parallel()
+			got, err := newStore(t, ps).Search(t.Context(), products.Query{Sort: products.SortPrice, Page: 1, PageSize: 1})
+			require.NoError(t, err)
+			assert.Equal(t, products.Page{Products: ps[0:1], Total: len(ps)}, got)
+		})
+
+		t.Run("invalid query", func(t *testing.T) {
+			t.Parallel()
+			_, err := newStore(t, ps).Search(t.Context(), products.Query{Sort: "colour", PageSize: 1})
+			assertCode(t, err, apperr.CodeValidation)
+			assert.ErrorContains(t, err, `unknown sort field "colour"`)
+
+			_, err = newStore(t, ps).Search(t.Context(), products.Query{PageSize: 0})
+			assertCode(t, err, apperr.CodeValidation)
+		})
+	})
+
+	t.Run("Version", func(t *testing.T) {
+		t.Parallel()
+		version := func(ps []products.Product) products.Version {
+			t.Helper()
+			v, err := newStore(t, ps).Version(t.Context())
+			require.NoError(t, err)
+			return v
+		}
+		a := version(ps)
+		assert.NotEmpty(t, a.Hash)
+		assert.Equal(t, a.Hash, version(ps).Hash, "same products same version")
+
+		changed := slices.Clone(ps)
+		changed[0].Price = money.New(760, "AUD")
+		assert.NotEqual(t, a.Hash, version(changed).Hash, "different products different version")
+	})
+
+	t.Run("concurrent use", func(t *testing.T) {
+		t.Parallel()
+		s := newStore(t, ps)
+		wg := sync.WaitGroup{}
+		for i := range concurrency {
+			wg.Go(func() {
+				want := ps[i%len(ps)]
+				got, err := s.Get(t.Context(), want.ID)
+				assert.NoError(t, err)
+				assert.Equal(t, want, got)
+				page, err := s.List(t.Context(), i%len(ps), 1)
+				assert.NoError(t, err)
+				assert.Equal(t, []products.Product{want}, page)
+			})
+		}
+		wg.Wait()
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		t.Parallel()
+		s := newStore(t, ps)
+		ctx := cancelled(t)
+
+		_, err := s.Get(ctx, ps[0].ID)
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = s.List(ctx, 0, 1)
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = s.Search(ctx, products.Query{PageSize: 1})
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = s.Version(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,38 @@
+// package storetest contains conformance suites that every implementation of a store interface
+// must pass, so implementations can be swapped without changing behaviour.
+//
+// Call a suite from the tests of the package holding the implementation, for example:
+//
+//	func TestSlice_Conformance(t *testing.T) {
+//		storetest.Products(t, func(t *testing.T, ps []products.Product) products.Store {
+//			return products.Slice(ps)
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrency is the number of goroutines used to check a store is safe for concurrent use.
+const concurrency = 16
+
+// assertCode checks err is an [apperr.Error] with code.
+func assertCode(t *testing.T, err error, code apperr.Code) {
+	t.Helper()
+	var ae apperr.Error
+	require.ErrorAs(t, err, &ae, "err must be an app error")
+	assert.Equal(t, code, ae.Code)
+}
+
+// cancelled returns a context that is already cancelled.
+func cancelled(t *testing.T) context.Context {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	return ctx
+}
@@ -0,0 +1,89 @@
+// package txn groups writes made to several stores into a single unit of work that is either
+// kept as a whole or rolled back.
+//
+// Our stores don't share a database transaction so they take part by registering a compensating
+// action for every write they make with [OnRollback]. Rolling back runs them in reverse order.
+// A write that can't be undone should be made last so nothing after it can fail.
+package txn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+type txKey struct{}
+
+// Tx is a unit of work started by [Begin].
+type Tx struct {
+	mu   sync.Mutex
+	undo []func(context.Context) error
+	done bool
+}
+
+// Begin starts a unit of work, writes made with the returned context take part in it.
+func Begin(ctx context.Context) (context.Context, *Tx) {
+	tx := &Tx{}
+	return context.WithValue(ctx, txKey{}, tx), tx
+}
+
+// Run calls fn in a new unit of work committing it if fn succeeds, otherwise rolling it back and
+// returning the error from fn as is, joined with any from rolling back.
+func Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx, tx := Begin(ctx)
+	if err := fn(ctx); err != nil {
+		if rerr := tx.Rollback(ctx); rerr != nil {
+			return errors.Join(err, fmt.Errorf("failed to roll back: %w", rerr))
+		}
+		return err
+	}
+	tx.Commit()
+	return nil
+}
+
+// OnRollback registers undo to be called if the unit of work ctx was made by is rolled back.
+// Writes made outside a unit of work, or after it has ended, are final so undo is dropped.
+func OnRollback(ctx context.Context, undo func(ctx context.Context) error) {
+	tx, ok := ctx.Value(txKey{}).(*Tx)
+	if !ok {
+		return
+	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if !tx.done {
+		tx.undo = append(tx.undo, undo)
+	}
+}
+
+// Commit ends tx keeping every write made in it.
+func (tx *Tx) Commit() {
+	tx.end()
+}
+
+// Rollback ends tx undoing every write made in it, most recent first. Every write is undone even
+// when ctx is cancelled or undoing an earlier one fails, the errors of any that fail are
+// returned. Does nothing once tx has ended.
+func (tx *Tx) Rollback(ctx context.Context) error {
+	undo := tx.end()
+	ctx = context.WithoutCancel(ctx)
+	ve := []error{}
+	for i := len(undo) - 1; i >= 0; i-- {
+		if err := undo[i](ctx); err != nil {
+			ve = append(ve, err)
+		}
+	}
+	if len(ve) > 0 {
+		return errors.Join(ve...)
+	}
+	return nil
+}
+
+// end marks tx as ended returning the writes that would need undoing.
+func (tx *Tx) end() []func(context.Context) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	undo := tx.undo
+	tx.undo, tx.done = nil, true
+	return undo
+}
@@ -0,0 +1,91 @@
+package txn
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	// write returns a write that records its undo in undone.
+	write := func(undone *[]string, name string, err error) func(ctx context.Context) {
+		return func(ctx context.Context) {
+			OnRollback(ctx, func(ctx context.Context) error {
+				*undone = append(*undone, name)
+				return err
+			})
+		}
+	}
+
+	t.Run("commit keeps writes", func(t *testing.T) {
+		t.Parallel()
+		undone := []string{}
+		err := Run(t.Context(), func(ctx context.Context) error {
+			write(&undone, "a", nil)(ctx)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Empty(t, undone)
+	})
+
+	t.Run("failure undoes writes in reverse", func(t *testing.T) {
+		t.Parallel()
+		undone := []string{}
+		err := Run(t.Context(), func(ctx context.Context) error {
+			write(&undone, "a", nil)(ctx)
+			write(&undone, "b", errors.New("b stuck"))(ctx)
+			write(&undone, "c", nil)(ctx)
+			return errors.New("nope")
+		})
+		assert.EqualError(t, err, "nope\nfailed to roll back: b stuck")
+		assert.Equal(t, []string{"c", "b", "a"}, undone, "every write is undone even when one fails")
+	})
+
+	t.Run("undo runs after cancellation", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(t.Context())
+		var undoErr error
+		err := Run(ctx, func(ctx context.Context) error {
+			OnRollback(ctx, func(ctx context.Context) error {
+				undoErr = ctx.Err()
+				return nil
+			})
+			cancel()
+			return ctx.Err()
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.NoError(t, undoErr)
+	})
+}
+
+func TestTx(t *testing.T) {
+	t.Parallel()
+
+	t.Run("writes outside a unit of work are final", func(t *testing.T) {
+		t.Parallel()
+		called := false
+		OnRollback(t.Context(), func(context.Context) error {
+			called = true
+			return nil
+		})
+		assert.False(t, called)
+	})
+
+	t.Run("ended unit of work", func(t *testing.T) {
+		t.Parallel()
+		ctx, tx := Begin(t.Context())
+		tx.Commit()
+		called := false
+		OnRollback(ctx, func(context.Context) error {
+			called = true
+			return nil
+		})
+		require.NoError(t, tx.Rollback(ctx))
+		assert.False(t, called, "writes after commit aren't part of it")
+	})
+}
@@ -0,0 +1,185 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/matgreaves/kart-challenge/api/apperr"
+)
+
+const (
+	// DefaultMaxAttempts is the number of times a delivery is tried before it's dead lettered.
+	DefaultMaxAttempts = 8
+	// DefaultBaseDelay is the delay before the first retry, it doubles after every failed attempt.
+	DefaultBaseDelay = time.Second
+	// DefaultMaxDelay caps the delay between retries.
+	DefaultMaxDelay = 10 * time.Minute
+	// DefaultPollInterval is how often the outbox is checked for deliveries that are due.
+	DefaultPollInterval = time.Second
+	// DefaultTimeout is how long a receiver has to respond to a delivery.
+	DefaultTimeout = 10 * time.Second
+
+	// batchSize is the most deliveries attempted per poll.
+	batchSize = 100
+)
+
+// Dispatcher delivers events waiting in a [Store] to their subscriptions.
+//
+// A delivery succeeds when the receiver responds with a 2xx status. Anything else is retried with
+// exponential backoff until MaxAttempts is reached, after which the delivery is dead lettered.
+// Deliveries are at least once, receivers should use the [HeaderID] header to drop duplicates.
+type Dispatcher struct {
+	Store  Store
+	Logger *slog.Logger
+	// Client sends deliveries, when nil a client with [DefaultTimeout] is used.
+	Client *http.Client
+	// Zero values use the matching default.
+	MaxAttempts  int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	PollInterval time.Duration
+}
+
+// Run delivers events until ctx is cancelled.
+func (d Dispatcher) Run(ctx context.Context) error {
+	d = d.withDefaults()
+	t := time.NewTicker(d.PollInterval)
+	defer t.Stop()
+	for {
+		if err := d.Dispatch(ctx); err != nil {
+			d.Logger.ErrorContext(ctx, "failed to dispatch webhooks", slog.String("error", err.Error()))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// Dispatch tries every delivery that is currently due once.
+func (d Dispatcher) Dispatch(ctx context.Context) error {
+	d = d.withDefaults()
+	due, err := d.Store.Due(ctx, time.Now(), batchSize)
+	if err != nil {
+		return fmt.Errorf("failed to get due deliveries: %w", err)
+	}
+	subs, err := d.Store.ListSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	byID := make(map[string]Subscription, len(subs))
+	for _, v := range subs {
+		byID[v.ID] = v
+	}
+	ve := []error{}
+	for _, v := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		sub, has := byID[v.SubscriptionID]
+		if !has {
+			// deleted since we fetched the due deliveries, along with the delivery
+			continue
+		}
+		if err := d.record(ctx, v, d.deliver(ctx, sub, v)); err != nil {
+			ve = append(ve, err)
+		}
+	}
+	if len(ve) > 0 {
+		return errors.Join(ve...)
+	}
+	return nil
+}
+
+// record stores the outcome of an attempt to deliver v.
+func (d Dispatcher) record(ctx context.Context, v Delivery, derr error) error {
+	var err error
+	switch {
+	case derr == nil:
+		err = d.Store.Delivered(ctx, v.ID)
+	case v.Attempts+1 >= d.MaxAttempts:
+		d.Logger.WarnContext(ctx, "webhook delivery dead lettered",
+			slog.String("deliveryId", v.ID),
+			slog.String("subscriptionId", v.SubscriptionID),
+			slog.String("error", derr.Error()),
+		)
+		err = d.Store.DeadLetter(ctx, v.ID, derr.Error())
+	default:
+		err = d.Store.Retry(ctx, v.ID, time.Now().Add(d.Backoff(v.Attempts+1)), derr.Error())
+	}
+	var ae apperr.Error
+	if errors.As(err, &ae) && ae.Code == apperr.CodeNotFound {
+		// subscription deleted mid delivery
+		return nil
+	}
+	return err
+}
+
+// deliver makes a single attempt at sending v to sub.
+func (d Dispatcher) deliver(ctx context.Context, sub Subscription, v Delivery) error {
+	body, err := json.Marshal(v.Event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, v.Event.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, now, body))
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	// drain so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("receiver responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// Backoff returns how long to wait before trying again after attempts failed attempts.
+func (d Dispatcher) Backoff(attempts int) time.Duration {
+	d = d.withDefaults()
+	delay := d.BaseDelay
+	for i := 1; i < attempts && delay < d.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.MaxDelay)
+}
+
+func (d Dispatcher) withDefaults() Dispatcher {
+	if d.Client == nil {
+		d.Client = &http.Client{Timeout: DefaultTimeout}
+	}
+	if d.Logger == nil {
+		d.Logger = slog.New(slog.DiscardHandler)
+	}
+	if d.MaxAttempts <= 0 {
+		d.MaxAttempts = DefaultMaxAttempts
+	}
+	if d.BaseDelay <= 0 {
+		d.BaseDelay = DefaultBaseDelay
+	}
+	if d.MaxDelay <= 0 {
+		d.MaxDelay = DefaultMaxDelay
+	}
+	if d.PollInterval <= 0 {
+		d.PollInterval = DefaultPollInterval
+	}
+	return d
+}
@@ -0,0 +1,140 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matgreaves/kart-challenge/api/orders"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is a webhook endpoint that fails the first failures deliveries it receives.
+type receiver struct {
+	mu       sync.Mutex
+	secret   string
+	failures int
+	events   []orders.Event
+	errs     []error
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.failures > 0 {
+		rc.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	if err := Verify(rc.secret, r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature), body); err != nil {
+		rc.errs = append(rc.errs, err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var e orders.Event
+	if err := json.Unmarshal(body, &e); err != nil {
+		rc.errs = append(rc.errs, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get(HeaderID) != e.ID {
+		rc.errs = append(rc.errs, assert.AnError)
+	}
+	rc.events = append(rc.events, e)
+}
+
+func (rc *receiver) received() ([]orders.Event, []error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.events, rc.errs
+}
+
+func TestDispatcher(t *testing.T) {
+	t.Parallel()
+
+	setup := func(t *testing.T, failures int) (*Mem, *receiver, Dispatcher) {
+		rc := &receiver{secret: "shh", failures: failures}
+		srv := httptest.NewServer(rc)
+		t.Cleanup(srv.Close)
+		m := NewMem()
+		_, err := m.CreateSubscription(t.Context(), Subscription{URL: srv.URL, Secret: rc.secret})
+		require.NoError(t, err)
+		require.NoError(t, m.Publish(t.Context(), orders.Event{
+			ID:    "e1",
+			Type:  orders.EventCreated,
+			At:    time.Now(),
+			Order: orders.Order{ID: "o1"},
+		}))
+		return m, rc, Dispatcher{Store: m, Client: srv.Client(), MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	}
+
+	t.Run("delivers signed event", func(t *testing.T) {
+		t.Parallel()
+		m, rc, d := setup(t, 0)
+		require.NoError(t, d.Dispatch(t.Context()))
+		events, errs := rc.received()
+		assert.Empty(t, errs)
+		require.Len(t, events, 1)
+		assert.Equal(t, "o1", events[0].Order.ID)
+
+		due, err := m.Due(t.Context(), time.Now().Add(time.Hour), 10)
+		require.NoError(t, err)
+		assert.Empty(t, due, "delivered events leave the outbox")
+	})
+
+	t.Run("retries failed delivery", func(t *testing.T) {
+		t.Parallel()
+		_, rc, d := setup(t, 2)
+		for range 3 {
+			require.NoError(t, d.Dispatch(t.Context()))
+			time.Sleep(5 * time.Millisecond)
+		}
+		events, errs := rc.received()
+		assert.Empty(t, errs)
+		assert.Len(t, events, 1)
+	})
+
+	t.Run("dead letters after max attempts", func(t *testing.T) {
+		t.Parallel()
+		m, rc, d := setup(t, 100)
+		for range 4 {
+			require.NoError(t, d.Dispatch(t.Context()))
+			time.Sleep(5 * time.Millisecond)
+		}
+		events, _ := rc.received()
+		assert.Empty(t, events)
+		dead, err := m.DeadLetters(t.Context())
+		require.NoError(t, err)
+		require.Len(t, dead, 1)
+		assert.Equal(t, 3, dead[0].Attempts)
+		assert.Equal(t, "receiver responded with status 503", dead[0].LastError)
+	})
+
+	t.Run("run delivers in the background", func(t *testing.T) {
+		t.Parallel()
+		_, rc, d := setup(t, 1)
+		d.PollInterval = time.Millisecond
+		go func() { _ = d.Run(t.Context()) }()
+		assert.EventuallyWithT(t, func(c *assert.CollectT) {
+			events, _ := rc.received()
+			assert.Len(c, events, 1)
+		}, 5*time.Second, 5*time.Millisecond)
+	})
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	t.Parallel()
+	d := Dispatcher{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	assert.Equal(t, time.Second, d.Backoff(1))
+	assert.Equal(t, 2*time.Second, d.Backoff(2))
+	assert.Equal(t, 4*time.Second, d.Backoff(3))
+	assert.Equal(t, 8*time.Second, d.Backoff(4))
+	assert.Equal(t, 10*time.Second, d.Backoff(5))
+	assert.Equal(t, 10*time.Second, d.Backoff(100))
+}
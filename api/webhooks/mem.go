@@ -0,0 +1,186 @@
+package webhooks
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matgreaves/kart-challenge/api/apperr"
+	"github.com/matgreaves/kart-challenge/api/orders"
+)
+
+var _ Store = &Mem{}
+
+// NewMem creates an empty [Mem] store.
+func NewMem() *Mem {
+	return &Mem{
+		subs:       map[string]Subscription{},
+		deliveries: map[string]Delivery{},
+	}
+}
+
+// Mem is a [Store] that keeps subscriptions and deliveries in memory.
+type Mem struct {
+	mu         sync.Mutex
+	subs       map[string]Subscription
+	deliveries map[string]Delivery
+	dead       []Delivery
+}
+
+// CreateSubscription implements [Store.CreateSubscription], a secret is generated for s if it
+// doesn't have one.
+func (m *Mem) CreateSubscription(_ context.Context, s Subscription) (Subscription, error) {
+	if err := s.Validate(); err != nil {
+		return Subscription{}, apperr.NewError(apperr.CodeConstraint, err)
+	}
+	var err error
+	if s.ID, err = newID(); err != nil {
+		return Subscription{}, err
+	}
+	if s.Secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return Subscription{}, fmt.Errorf("webhooks failed to create secret: %w", err)
+		}
+		s.Secret = hex.EncodeToString(b)
+	}
+	s.CreatedAt = time.Now().UTC()
+	m.mu.Lock()
+	m.subs[s.ID] = s
+	m.mu.Unlock()
+	return s, nil
+}
+
+// ListSubscriptions implements [Store.ListSubscriptions].
+func (m *Mem) ListSubscriptions(_ context.Context) ([]Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subs := make([]Subscription, 0, len(m.subs))
+	for _, v := range m.subs {
+		subs = append(subs, v)
+	}
+	slices.SortFunc(subs, func(a, b Subscription) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	return subs, nil
+}
+
+// DeleteSubscription implements [Store.DeleteSubscription].
+func (m *Mem) DeleteSubscription(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, has := m.subs[id]; !has {
+		return apperr.NewError(apperr.CodeNotFound, fmt.Errorf("subscription %s not found", id))
+	}
+	delete(m.subs, id)
+	for k, v := range m.deliveries {
+		if v.SubscriptionID == id {
+			delete(m.deliveries, k)
+		}
+	}
+	return nil
+}
+
+// Publish implements [orders.Publisher].
+func (m *Mem) Publish(_ context.Context, e orders.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.subs {
+		if !s.Wants(e.Type) {
+			continue
+		}
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		m.deliveries[id] = Delivery{
+			ID:             id,
+			SubscriptionID: s.ID,
+			Event:          e,
+			NextAttempt:    e.At,
+		}
+	}
+	return nil
+}
+
+// Due implements [Store.Due].
+func (m *Mem) Due(_ context.Context, now time.Time, limit int) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	due := []Delivery{}
+	for _, v := range m.deliveries {
+		if !v.NextAttempt.After(now) {
+			due = append(due, v)
+		}
+	}
+	slices.SortFunc(due, func(a, b Delivery) int {
+		return cmp.Or(a.NextAttempt.Compare(b.NextAttempt), cmp.Compare(a.ID, b.ID))
+	})
+	return due[:min(limit, len(due))], nil
+}
+
+// Delivered implements [Store.Delivered].
+func (m *Mem) Delivered(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, has := m.deliveries[id]; !has {
+		return deliveryNotFound(id)
+	}
+	delete(m.deliveries, id)
+	return nil
+}
+
+// Retry implements [Store.Retry].
+func (m *Mem) Retry(_ context.Context, id string, next time.Time, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, has := m.deliveries[id]
+	if !has {
+		return deliveryNotFound(id)
+	}
+	d.Attempts++
+	d.NextAttempt = next
+	d.LastError = reason
+	m.deliveries[id] = d
+	return nil
+}
+
+// DeadLetter implements [Store.DeadLetter].
+func (m *Mem) DeadLetter(_ context.Context, id string, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, has := m.deliveries[id]
+	if !has {
+		return deliveryNotFound(id)
+	}
+	d.Attempts++
+	d.LastError = reason
+	delete(m.deliveries, id)
+	m.dead = append(m.dead, d)
+	return nil
+}
+
+// DeadLetters implements [Store.DeadLetters].
+func (m *Mem) DeadLetters(_ context.Context) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.dead), nil
+}
+
+func deliveryNotFound(id string) error {
+	return apperr.NewError(apperr.CodeNotFound, fmt.Errorf("delivery %s not found", id))
+}
+
+func newID() (string, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", fmt.Errorf("webhooks failed to create uuid: %w", err)
+	}
+	return id.String(), nil
+}
@@ -0,0 +1,125 @@
+// package webhooks delivers order events to subscribed http endpoints.
+//
+// Events are written to an outbox as they're published and delivered in the background by a
+// [Dispatcher]. Every delivery is signed so receivers can check it came from us, failed deliveries
+// are retried with exponential backoff before eventually being moved to a dead letter list.
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/matgreaves/kart-challenge/api/orders"
+)
+
+const (
+	// HeaderID carries the unique ID of the event being delivered, receivers can use it to
+	// ignore duplicate deliveries.
+	HeaderID = "Kart-Webhook-Id"
+	// HeaderTimestamp carries the unix time the delivery was signed at.
+	HeaderTimestamp = "Kart-Webhook-Timestamp"
+	// HeaderSignature carries the signature of the delivery, see [Sign].
+	HeaderSignature = "Kart-Webhook-Signature"
+)
+
+// Subscription registers URL to receive events.
+type Subscription struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Events the subscription receives, every event when empty.
+	Events []orders.EventType `json:"events,omitempty"`
+	// Secret is the key deliveries are signed with.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Validate checks whether s is well formed.
+func (s Subscription) Validate() error {
+	ve := []error{}
+	if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		ve = append(ve, errors.New("url must be an absolute http or https url"))
+	}
+	for _, v := range s.Events {
+		if !slices.Contains(orders.EventTypes, v) {
+			ve = append(ve, fmt.Errorf("unknown event %q", v))
+		}
+	}
+	if len(ve) > 0 {
+		return errors.Join(ve...)
+	}
+	return nil
+}
+
+// Wants reports whether s is subscribed to events of type t.
+func (s Subscription) Wants(t orders.EventType) bool {
+	return len(s.Events) == 0 || slices.Contains(s.Events, t)
+}
+
+// Delivery is a single event waiting to be, or that failed to be, delivered to a [Subscription].
+type Delivery struct {
+	ID             string       `json:"id"`
+	SubscriptionID string       `json:"subscriptionId"`
+	Event          orders.Event `json:"event"`
+	// Attempts is the number of times delivery has been tried.
+	Attempts int `json:"attempts"`
+	// NextAttempt is the earliest time the delivery will next be tried.
+	NextAttempt time.Time `json:"nextAttempt"`
+	// LastError describes why the last attempt failed.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Store is the interface for interacting with subscriptions and the outbox of deliveries.
+//
+// Publishing an event creates a [Delivery] for every subscription that wants it.
+type Store interface {
+	orders.Publisher
+
+	CreateSubscription(ctx context.Context, s Subscription) (Subscription, error)
+	ListSubscriptions(ctx context.Context) ([]Subscription, error)
+	// DeleteSubscription removes a subscription along with any deliveries waiting for it.
+	DeleteSubscription(ctx context.Context, id string) error
+
+	// Due returns up to limit deliveries that are ready to be tried at now, oldest first.
+	Due(ctx context.Context, now time.Time, limit int) ([]Delivery, error)
+	// Delivered removes a delivery from the outbox once it has succeeded.
+	Delivered(ctx context.Context, id string) error
+	// Retry records a failed attempt, the delivery will be tried again at next.
+	Retry(ctx context.Context, id string, next time.Time, reason string) error
+	// DeadLetter records a failed attempt and moves the delivery to the dead letter list, it
+	// won't be tried again.
+	DeadLetter(ctx context.Context, id string, reason string) error
+	// DeadLetters lists deliveries that could not be delivered, oldest first.
+	DeadLetters(ctx context.Context) ([]Delivery, error)
+}
+
+// Sign returns the signature of a delivery of body signed at timestamp with secret.
+//
+// The signature is the hex encoded HMAC-SHA256 of the timestamp, a full stop and the body prefixed
+// with "sha256=". Including the timestamp lets receivers reject replayed deliveries.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks signature is a valid signature of body, as sent in a delivery's headers.
+func Verify(secret, timestamp, signature string, body []byte) error {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", HeaderTimestamp, err)
+	}
+	want := Sign(secret, time.Unix(unix, 0), body)
+	if !hmac.Equal([]byte(want), []byte(signature)) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
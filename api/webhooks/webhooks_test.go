@@ -0,0 +1,107 @@
+package webhooks
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/matgreaves/kart-challenge/api/orders"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	t.Parallel()
+	at := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"1"}`)
+	sig := Sign("secret", at, body)
+	ts := strconv.FormatInt(at.Unix(), 10)
+
+	assert.NoError(t, Verify("secret", ts, sig, body))
+	assert.Error(t, Verify("other", ts, sig, body), "wrong secret")
+	assert.Error(t, Verify("secret", ts, sig, []byte(`{"id":"2"}`)), "tampered body")
+	assert.Error(t, Verify("secret", "1700000001", sig, body), "tampered timestamp")
+	assert.Error(t, Verify("secret", "soon", sig, body), "invalid timestamp")
+}
+
+func TestSubscription_Validate(t *testing.T) {
+	t.Parallel()
+	assert.NoError(t, Subscription{URL: "https://example.com/hook"}.Validate())
+	assert.NoError(t, Subscription{URL: "http://localhost:9000", Events: []orders.EventType{orders.EventCreated}}.Validate())
+	assert.EqualError(t, Subscription{URL: "example.com"}.Validate(), "url must be an absolute http or https url")
+	assert.EqualError(t, Subscription{URL: "ftp://example.com"}.Validate(), "url must be an absolute http or https url")
+	assert.EqualError(t, Subscription{URL: "https://example.com", Events: []orders.EventType{"order.eaten"}}.Validate(), `unknown event "order.eaten"`)
+}
+
+func TestMem(t *testing.T) {
+	t.Parallel()
+
+	t.Run("publish fans out to interested subscriptions", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem()
+		all, err := m.CreateSubscription(t.Context(), Subscription{URL: "https://example.com/all"})
+		require.NoError(t, err)
+		assert.NotEmpty(t, all.Secret, "secret is generated")
+		created, err := m.CreateSubscription(t.Context(), Subscription{URL: "https://example.com/created", Events: []orders.EventType{orders.EventCreated}})
+		require.NoError(t, err)
+
+		at := time.Now()
+		require.NoError(t, m.Publish(t.Context(), orders.Event{ID: "e1", Type: orders.EventCreated, At: at}))
+		require.NoError(t, m.Publish(t.Context(), orders.Event{ID: "e2", Type: orders.EventStatusChanged, At: at}))
+
+		due, err := m.Due(t.Context(), at, 10)
+		require.NoError(t, err)
+		got := map[string][]string{}
+		for _, v := range due {
+			got[v.SubscriptionID] = append(got[v.SubscriptionID], v.Event.ID)
+		}
+		assert.ElementsMatch(t, []string{"e1", "e2"}, got[all.ID])
+		assert.Equal(t, []string{"e1"}, got[created.ID])
+	})
+
+	t.Run("retry and dead letter", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem()
+		_, err := m.CreateSubscription(t.Context(), Subscription{URL: "https://example.com"})
+		require.NoError(t, err)
+		at := time.Now()
+		require.NoError(t, m.Publish(t.Context(), orders.Event{ID: "e1", Type: orders.EventCreated, At: at}))
+		due, err := m.Due(t.Context(), at, 10)
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+
+		require.NoError(t, m.Retry(t.Context(), due[0].ID, at.Add(time.Minute), "boom"))
+		due, err = m.Due(t.Context(), at, 10)
+		require.NoError(t, err)
+		assert.Empty(t, due, "not due until the retry time")
+		due, err = m.Due(t.Context(), at.Add(time.Minute), 10)
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+		assert.Equal(t, 1, due[0].Attempts)
+		assert.Equal(t, "boom", due[0].LastError)
+
+		require.NoError(t, m.DeadLetter(t.Context(), due[0].ID, "bang"))
+		due, err = m.Due(t.Context(), at.Add(time.Hour), 10)
+		require.NoError(t, err)
+		assert.Empty(t, due)
+		dead, err := m.DeadLetters(t.Context())
+		require.NoError(t, err)
+		require.Len(t, dead, 1)
+		assert.Equal(t, 2, dead[0].Attempts)
+		assert.Equal(t, "bang", dead[0].LastError)
+	})
+
+	t.Run("delete subscription drops pending deliveries", func(t *testing.T) {
+		t.Parallel()
+		m := NewMem()
+		s, err := m.CreateSubscription(t.Context(), Subscription{URL: "https://example.com"})
+		require.NoError(t, err)
+		at := time.Now()
+		require.NoError(t, m.Publish(t.Context(), orders.Event{ID: "e1", Type: orders.EventCreated, At: at}))
+		require.NoError(t, m.DeleteSubscription(t.Context(), s.ID))
+		due, err := m.Due(t.Context(), at, 10)
+		require.NoError(t, err)
+		assert.Empty(t, due)
+		assert.Error(t, m.DeleteSubscription(t.Context(), s.ID))
+	})
+}
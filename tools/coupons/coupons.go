@@ -13,9 +13,11 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+
+	"github.com/matgreaves/kart-challenge/api/coupons"
 )
 
-// usage: `go run ./tools/coupons1 -f "tmp/coupons/couponbase1 tmp/coupons/couponbase2 tmp/coupons/couponbase3"`
+// usage: `go run ./tools/coupons -f "tmp/coupons/couponbase1 tmp/coupons/couponbase2 tmp/coupons/couponbase3"`
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT)
 	defer cancel()
@@ -27,7 +29,11 @@ func main() {
 func run(ctx context.Context, to io.Writer, args []string) error {
 	set := flag.NewFlagSet("", flag.PanicOnError)
 	files := set.String("f", "", "comma separated list of coupon files to check")
+	format := set.String("format", "packed", "output format, packed for the binary database read by coupons.NewPacked or text for one code per line")
 	set.Parse(args)
+	if *format != "packed" && *format != "text" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
 
 	fileNames := strings.Split(*files, " ")
 	seen := map[string]int{}
@@ -62,6 +68,16 @@ func run(ctx context.Context, to io.Writer, args []string) error {
 		}
 	}
 
+	if *format == "packed" {
+		b, err := coupons.Pack(seenTwo)
+		if err != nil {
+			return fmt.Errorf("failed to pack seen: %w", err)
+		}
+		if _, err := to.Write(b); err != nil {
+			return fmt.Errorf("failed to write seen: %w", err)
+		}
+		return nil
+	}
 	for _, v := range seenTwo {
 		if _, err := io.WriteString(to, v+"\n"); err != nil {
 			return fmt.Errorf("failed to write seen: %w", err)